@@ -0,0 +1,44 @@
+#!/bin/sh
+
+# Times the example programs with the current interpreter. If a git ref is
+# given (i.e. "./bench.sh main"), the examples are also timed with the
+# interpreter from that ref so that the results can be compared.
+#
+# Each interpreter is built as an executable, and timed by running the
+# executable, so any ref that builds can be compared. For more precise
+# in-process numbers for the current interpreter, use:
+#
+#   go test -bench . ./src/interpreter
+#
+# Usage: ./bench.sh [git ref] [bench flags]
+
+set -e
+
+SCRIPT_DIR="$(CDPATH= command cd -- "$(dirname -- "$0")" && pwd -P)"
+cd "$SCRIPT_DIR"
+
+BIN_DIR="$(mktemp -d)"
+cleanup() {
+    rm -rf "$BIN_DIR"
+    if [ -n "$REF_DIR" ]; then
+        git worktree remove --force "$REF_DIR"
+    fi
+}
+trap cleanup EXIT
+
+if [ $# -gt 0 ] && [ "${1#-}" = "$1" ]; then
+    REF="$1"
+    shift
+
+    REF_DIR="$(mktemp -d)"
+    git worktree add -q --detach "$REF_DIR" "$REF"
+    (cd "$REF_DIR" && go build -o "$BIN_DIR/y2k-ref")
+
+    echo "- $REF"
+    go run ./src/bench -bin "$BIN_DIR/y2k-ref" "$@"
+fi
+
+go build -o "$BIN_DIR/y2k"
+
+echo "- Current"
+go run ./src/bench -bin "$BIN_DIR/y2k" "$@"
@@ -1,14 +1,36 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"flag"
 	"fmt"
+	"github.com/benbusby/y2k/src/asm"
+	"github.com/benbusby/y2k/src/debugger"
 	"github.com/benbusby/y2k/src/interpreter"
 	"github.com/benbusby/y2k/src/utils"
+	"os"
 )
 
+// usage is printed when y2k is run without a program.
+const usage = `Usage: y2k [args] <directory|file> [program args]
+       y2k debug [args] <directory|file> [program args]
+       y2k disasm [args] <directory|file>
+       y2k asm [args] <file>`
+
 func main() {
 	var timestamp string
+
+	// Subcommands are removed from the arguments before the flags are
+	// parsed, so that flags can be used with them (i.e. "y2k debug -d 2").
+	subcommand := ""
+	if len(os.Args) > 1 && (os.Args[1] == "debug" ||
+		os.Args[1] == "disasm" ||
+		os.Args[1] == "asm") {
+		subcommand = os.Args[1]
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
 	digits := flag.Int(
 		"d",
 		1,
@@ -26,22 +48,51 @@ func main() {
 		"./y2k-out",
 		"Set the output directory for timestamp-only files when exporting a raw Y2K file.\n"+
 			"This directory will be created if it does not exist.")
+	commands := flag.Bool(
+		"commands",
+		false,
+		"Print a reference of all Y2K commands and their fields")
 	flag.Parse()
 
-	y2k := &interpreter.Y2K{Digits: *digits, Debug: *debug}
+	if *commands {
+		fmt.Println(interpreter.Help())
+		return
+	}
+
+	// Assembly files are read as text, rather than as Y2K digits
+	if subcommand == "asm" {
+		if flag.NArg() == 0 {
+			fmt.Println("Missing input file!\n\n" + usage)
+			flag.PrintDefaults()
+			return
+		}
+
+		exitOnError(assemble(flag.Arg(0), *digits, *export, *outdir))
+		return
+	}
+
+	// The debugger and the interpreter share the same reader, so that
+	// neither one can buffer input that is meant for the other.
+	stdin := bufio.NewReader(os.Stdin)
+	y2k := interpreter.New(*digits, *debug, stdin, os.Stdout)
 
 	for _, arg := range flag.Args() {
 		// Assume first argument is the directory or file to use for parsing
 		if len(timestamp) == 0 {
+			var err error
 			if *export {
 				// If we're exporting, assume we're only reading raw Y2K file
 				// contents, and export to a set of empty files.
-				timestamp = utils.ReadY2KRawFile(arg)
+				timestamp, _, err = utils.ReadY2KRawFile(arg)
+				if err == nil {
+					err = utils.ExportRawToTimestampFiles(timestamp, *outdir)
+				}
 
-				utils.ExportRawToTimestampFiles(timestamp, *outdir)
+				exitOnError(err)
 				return
 			} else {
-				timestamp = utils.GetTimestamps(arg, *digits)
+				timestamp, y2k.Source, err = utils.GetTimestamps(arg, *digits)
+				exitOnError(err)
 			}
 			continue
 		}
@@ -50,10 +101,57 @@ func main() {
 	}
 
 	if len(timestamp) == 0 {
-		fmt.Println("Missing input dir!\n\nUsage: y2k <directory> [args]")
+		fmt.Println("Missing input dir!\n\n" + usage)
 		flag.PrintDefaults()
 		return
 	}
 
-	y2k.Parse(timestamp)
+	switch subcommand {
+	case "debug":
+		program, err := y2k.Compile(timestamp)
+		exitOnError(err)
+
+		session := y2k.NewSession(program)
+		exitOnError(debugger.New(session, program, y2k.Source, stdin, os.Stdout).Run())
+		return
+	case "disasm":
+		exitOnError(asm.Disassemble(os.Stdout, y2k, timestamp))
+		return
+	}
+
+	exitOnError(y2k.Parse(timestamp))
+}
+
+// assemble converts an assembly file to Y2K, and either writes it to stdout
+// as a raw Y2K file or exports it to a set of timestamp-only files.
+func assemble(path string, digits int, export bool, outdir string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	out, err := asm.Assemble(file, digits)
+	if err != nil {
+		return err
+	}
+
+	if export {
+		return utils.ExportRawToTimestampFiles(out.Timestamp, outdir)
+	}
+
+	return out.WriteRaw(os.Stdout)
+}
+
+// exitOnError prints an error to stderr and exits with a non-zero status
+// code if the error is not nil. Programs that end with an EXIT command exit
+// with the command's status code instead, without printing anything.
+func exitOnError(err error) {
+	var exitErr *interpreter.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.Code)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
 }
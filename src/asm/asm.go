@@ -0,0 +1,1397 @@
+package asm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/benbusby/y2k/src/interpreter"
+	"github.com/benbusby/y2k/src/utils"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxDigits is the largest number of digits the assembler will switch to
+// when a value doesn't fit in fewer digits, which is enough for any Unicode
+// code point.
+const maxDigits = 7
+
+// maxSize is the largest value of the size fields of CREATE, MODIFY and
+// CONDITION commands.
+const maxSize = 255
+
+var (
+	ErrSyntax      = errors.New("syntax error")
+	ErrUnencodable = errors.New("value can't be encoded")
+)
+
+// Error is returned by Assemble when a line of assembly can't be assembled.
+type Error struct {
+	Line int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Output is a program that was assembled from Y2K assembly.
+type Output struct {
+	// Timestamp holds the digits of the program, which can be exported to
+	// a set of timestamp files.
+	Timestamp string
+
+	digits int
+	lines  []outputLine
+}
+
+// WriteRaw writes the program as a commented raw Y2K file, with the line of
+// assembly that each instruction was created from as its comment.
+func (out *Output) WriteRaw(w io.Writer) error {
+	header := ""
+	if out.digits > 1 {
+		header = fmt.Sprintf("Run with -d %d", out.digits)
+	}
+
+	return writeLines(w, header, out.lines)
+}
+
+// literalKind is the data type of a literal value in Y2K assembly.
+type literalKind uint8
+
+const (
+	litInt literalKind = iota
+	litFloat
+	litString
+	litVar
+)
+
+// literal is a value written in Y2K assembly, i.e. 42, 3.14, "hi" or v1.
+type literal struct {
+	kind literalKind
+	text string
+	id   uint8
+}
+
+// encoded is an instruction that has been encoded for a particular number of
+// digits. The value of the instruction is either a list of codes (which
+// each take up one chunk, such as character codes), or a number which is
+// split into as many chunks as needed.
+type encoded struct {
+	command interpreter.Y2KCommand
+	fields  []int
+	codes   []int
+	number  string
+	noValue bool
+	comment string
+}
+
+// asmBlock is a condition or subroutine whose closing brace hasn't been
+// reached yet.
+type asmBlock struct {
+	term    string
+	line    int
+	base    int
+	digits  int
+	debug   bool
+	unicode bool
+	stderr  bool
+	hasElse bool
+	define  bool
+}
+
+// assembler tracks the number of digits being parsed and the blocks that are
+// open, in the same way that the interpreter would when running the output.
+type assembler struct {
+	base      int
+	digits    int
+	debug     bool
+	unicode   bool
+	stderr    bool
+	timestamp strings.Builder
+	lines     []outputLine
+	blocks    []asmBlock
+
+	lineNum int
+	text    string
+}
+
+// Assemble reads a program written in Y2K assembly and encodes it as Y2K
+// digits, for an interpreter that starts out parsing the given number of
+// digits at a time. META commands are inserted automatically when a value
+// needs more digits than are currently being parsed. See the README for
+// the syntax of Y2K assembly.
+func Assemble(r io.Reader, digits int) (*Output, error) {
+	a := &assembler{base: digits, digits: digits}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		a.lineNum++
+
+		tokens, err := tokenize(scanner.Text())
+		if err == nil && len(tokens) > 0 {
+			a.text = strings.Join(tokens, " ")
+			err = a.statement(tokens)
+		}
+
+		var asmErr *Error
+		if errors.As(err, &asmErr) {
+			return nil, err
+		} else if err != nil {
+			return nil, &Error{Line: a.lineNum, Err: err}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(a.blocks) > 0 {
+		top := a.blocks[len(a.blocks)-1]
+		return nil, &Error{Line: top.line, Err: fmt.Errorf("%w: missing }", ErrSyntax)}
+	}
+
+	return &Output{
+		Timestamp: a.timestamp.String(),
+		digits:    digits,
+		lines:     a.lines,
+	}, nil
+}
+
+// tokenize splits a line of assembly into tokens, which are separated by
+// whitespace. Quoted strings are kept as a single token, braces are always
+// their own token, and anything after a "#" is a comment.
+func tokenize(line string) ([]string, error) {
+	var tokens []string
+	token := ""
+
+	flush := func() {
+		if len(token) > 0 {
+			tokens = append(tokens, token)
+			token = ""
+		}
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '#':
+			flush()
+			return tokens, nil
+		case c == '"':
+			end := i + 1
+			for ; end < len(line) && line[end] != '"'; end++ {
+				if line[end] == '\\' {
+					end++
+				}
+			}
+
+			if end >= len(line) {
+				return nil, fmt.Errorf("%w: unterminated string", ErrSyntax)
+			}
+
+			token += line[i : end+1]
+			i = end
+		case c == '{' || c == '}':
+			flush()
+			tokens = append(tokens, string(c))
+		case c == ' ' || c == '\t' || c == '\r':
+			flush()
+		default:
+			token += string(c)
+		}
+	}
+
+	flush()
+	return tokens, nil
+}
+
+// statement assembles a single line of tokens.
+func (a *assembler) statement(tokens []string) error {
+	switch strings.ToLower(tokens[0]) {
+	case "var":
+		return a.create(tokens)
+	case "print", "write":
+		return a.print(tokens)
+	case "input":
+		return a.input(tokens)
+	case "list":
+		return a.list(tokens)
+	case "string":
+		return a.str(tokens)
+	case "convert":
+		return a.convert(tokens)
+	case "if", "while":
+		return a.condition(tokens)
+	case "}":
+		if len(tokens) == 3 && strings.ToLower(tokens[1]) == "else" && tokens[2] == "{" {
+			return a.elseBranch()
+		} else if len(tokens) > 1 {
+			return fmt.Errorf("%w: unexpected %s after }", ErrSyntax, tokens[1])
+		}
+		return a.closeBlock()
+	case "continue":
+		if len(tokens) > 1 {
+			return fmt.Errorf("%w: unexpected %s after continue", ErrSyntax, tokens[1])
+		}
+		return a.emit(a.text, func(int) (encoded, error) {
+			return encoded{command: interpreter.CONTINUE, noValue: true}, nil
+		})
+	case "break":
+		return a.breakLoop(tokens)
+	case "sub":
+		return a.define(tokens)
+	case "call":
+		return a.call(tokens)
+	case "return":
+		return a.returnSub(tokens)
+	case "exit":
+		return a.exit(tokens)
+	case "digits":
+		return a.setDigits(tokens)
+	case "debug":
+		return a.setDebug(tokens)
+	case "charset":
+		return a.setCharset(tokens)
+	case "output":
+		return a.setOutput(tokens)
+	}
+
+	if strings.HasPrefix(strings.ToLower(tokens[0]), "v") {
+		return a.modify(tokens)
+	}
+
+	return fmt.Errorf("%w: unknown statement %q", ErrSyntax, tokens[0])
+}
+
+// create assembles "var <id> = <value>", along with "var <id> = [<var>,
+// ...]" for a list and "var <id> = args" for a list of the command line
+// arguments.
+func (a *assembler) create(tokens []string) error {
+	if len(tokens) < 4 || tokens[2] != "=" {
+		return fmt.Errorf("%w: expected var <id> = <value>", ErrSyntax)
+	}
+
+	id, err := parseID(strings.TrimPrefix(strings.ToLower(tokens[1]), "v"))
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(tokens[3], "[") {
+		return a.createList(id, strings.Join(tokens[3:], " "))
+	} else if len(tokens) != 4 {
+		return fmt.Errorf("%w: expected var <id> = <value>", ErrSyntax)
+	}
+
+	if strings.ToLower(tokens[3]) == "args" {
+		return a.emit(a.text, func(int) (encoded, error) {
+			return encoded{
+				command: interpreter.CREATE,
+				fields:  []int{int(id), int(interpreter.Y2KArgs), 0},
+			}, nil
+		})
+	}
+
+	value, err := parseLiteral(tokens[3])
+	if err != nil {
+		return err
+	}
+
+	return a.emit(a.text, func(int) (encoded, error) {
+		enc := encoded{command: interpreter.CREATE}
+
+		switch value.kind {
+		case litString:
+			codes, err := encodeString(value.text, a.unicode)
+			if err != nil {
+				return enc, err
+			}
+
+			enc.fields = []int{int(id), int(interpreter.Y2KString), len(codes)}
+			enc.codes = codes
+		case litVar:
+			enc.number = strconv.Itoa(int(value.id))
+			enc.fields = []int{int(id), int(interpreter.Y2KVarCopy), len(enc.number)}
+		case litFloat:
+			// The first digit of a float is the position of the decimal
+			parts := strings.SplitN(value.text, ".", 2)
+			if len(parts[0]) > 9 {
+				return enc, fmt.Errorf(
+					"%w: floats can have at most 9 digits before the decimal",
+					ErrUnencodable)
+			}
+
+			enc.number = strconv.Itoa(len(parts[0])) + parts[0] + parts[1]
+			enc.fields = []int{int(id), int(interpreter.Y2KFloat), len(enc.number)}
+		default:
+			enc.number = value.text
+			enc.fields = []int{int(id), int(interpreter.Y2KInt), len(enc.number)}
+		}
+
+		return enc, checkSize(enc.fields[2])
+	})
+}
+
+// createList assembles a list of variables, i.e. "[v1, v2]" or "[]". The
+// ID of each variable takes up one chunk.
+func (a *assembler) createList(id uint8, list string) error {
+	if !strings.HasSuffix(list, "]") {
+		return fmt.Errorf("%w: expected ] at the end of the list", ErrSyntax)
+	}
+
+	var items []int
+	for _, token := range strings.FieldsFunc(list[1:len(list)-1], func(c rune) bool {
+		return c == ',' || c == ' '
+	}) {
+		item, err := parseLiteral(token)
+		if err != nil || item.kind != litVar {
+			return fmt.Errorf("%w: lists can only be created from variables, not %q", ErrSyntax, token)
+		}
+
+		items = append(items, int(item.id))
+	}
+
+	return a.emit(a.text, func(int) (encoded, error) {
+		return encoded{
+			command: interpreter.CREATE,
+			fields:  []int{int(id), int(interpreter.Y2KList), len(items)},
+			codes:   items,
+		}, checkSize(len(items))
+	})
+}
+
+// inlineTypes holds the PRINT type that doesn't end with a newline for
+// each PRINT type, which is used by "write".
+var inlineTypes = map[interpreter.Y2KPrintType]interpreter.Y2KPrintType{
+	interpreter.Y2KPrintString: interpreter.Y2KPrintStringInline,
+	interpreter.Y2KPrintVar:    interpreter.Y2KPrintVarInline,
+	interpreter.Y2KPrintVars:   interpreter.Y2KPrintVarsInline,
+	interpreter.Y2KPrintNumber: interpreter.Y2KPrintNumberInline,
+}
+
+// print assembles "print <string>", "print <var>" or "print var <var>", along
+// with "print <var>, <var>, ... [sep <string>]" for several variables (which
+// are separated by a space, unless a single character separator is given),
+// and "print <var> [decimals <n>] [width <n>] [zeros]" for a formatted
+// number. Each of these can be written with "write" instead of "print" to
+// leave out the newline at the end.
+func (a *assembler) print(tokens []string) error {
+	keyword := strings.ToLower(tokens[0])
+	printType := func(printType interpreter.Y2KPrintType) int {
+		if keyword == "write" {
+			return int(inlineTypes[printType])
+		}
+
+		return int(printType)
+	}
+
+	if len(tokens) == 3 && strings.ToLower(tokens[1]) == "var" {
+		tokens = []string{tokens[0], tokens[2]}
+	}
+
+	if len(tokens) > 2 && (strings.HasSuffix(tokens[1], ",") || strings.ToLower(tokens[2]) == "sep") {
+		return a.printVars(tokens, printType(interpreter.Y2KPrintVars))
+	} else if len(tokens) > 2 {
+		return a.printNumber(tokens, printType(interpreter.Y2KPrintNumber))
+	}
+
+	if len(tokens) != 2 {
+		return fmt.Errorf("%w: expected %s <value>", ErrSyntax, keyword)
+	}
+
+	value, err := parseLiteral(tokens[1])
+	if err != nil {
+		return err
+	}
+
+	switch value.kind {
+	case litString:
+		codes, err := encodeString(value.text, a.unicode)
+		if err != nil {
+			return err
+		}
+
+		return a.emit(a.text, func(int) (encoded, error) {
+			return encoded{
+				command: interpreter.PRINT,
+				fields:  []int{printType(interpreter.Y2KPrintString), len(codes)},
+				codes:   codes,
+			}, nil
+		})
+	case litVar:
+		// The variable ID is padded so that it fills a whole number of
+		// chunks, since every chunk of the value is read.
+		return a.emit(a.text, func(digits int) (encoded, error) {
+			id := strconv.Itoa(int(value.id))
+			size := (len(id) + digits - 1) / digits
+			return encoded{
+				command: interpreter.PRINT,
+				fields:  []int{printType(interpreter.Y2KPrintVar), size},
+				number:  fmt.Sprintf("%0*s", size*digits, id),
+			}, nil
+		})
+	}
+
+	return fmt.Errorf("%w: only strings and variables can be printed", ErrSyntax)
+}
+
+// printVars assembles "print <var>, <var>, ... [sep <string>]". The
+// separator takes up the first chunk of the value, and each variable ID
+// takes up one chunk after it.
+func (a *assembler) printVars(tokens []string, printType int) error {
+	args := tokens[1:]
+	separator := " "
+	if len(args) > 2 && strings.ToLower(args[len(args)-2]) == "sep" {
+		sep, err := parseLiteral(args[len(args)-1])
+		if err != nil || sep.kind != litString || len([]rune(sep.text)) != 1 {
+			return fmt.Errorf("%w: the separator must be a single character string", ErrSyntax)
+		}
+
+		separator = sep.text
+		args = args[:len(args)-2]
+	}
+
+	codes, err := encodeString(separator, a.unicode)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range strings.FieldsFunc(strings.Join(args, " "), func(c rune) bool {
+		return c == ',' || c == ' '
+	}) {
+		item, err := parseLiteral(token)
+		if err != nil || item.kind != litVar {
+			return fmt.Errorf("%w: expected a variable, not %q", ErrSyntax, token)
+		}
+
+		codes = append(codes, int(item.id))
+	}
+
+	return a.emit(a.text, func(int) (encoded, error) {
+		return encoded{
+			command: interpreter.PRINT,
+			fields:  []int{printType, len(codes) - 1},
+			codes:   codes,
+		}, nil
+	})
+}
+
+// printNumber assembles "print <var> [decimals <n>] [width <n>] [zeros]",
+// which prints a number with a fixed number of decimal places, padded to a
+// minimum width with spaces (or with zeros).
+func (a *assembler) printNumber(tokens []string, printType int) error {
+	value, err := parseLiteral(tokens[1])
+	if err != nil || value.kind != litVar {
+		return fmt.Errorf("%w: only variables can be formatted, not %q", ErrSyntax, tokens[1])
+	}
+
+	options := map[string]int{}
+	for i := 2; i < len(tokens); i++ {
+		option := strings.ToLower(tokens[i])
+		switch option {
+		case "zeros":
+			options[option] = 1
+			continue
+		case "decimals", "width":
+			if i+1 < len(tokens) {
+				if n, err := strconv.Atoi(tokens[i+1]); err == nil && n >= 0 {
+					options[option] = n
+					i++
+					continue
+				}
+			}
+		}
+
+		return fmt.Errorf("%w: expected %s <var> [decimals <n>] [width <n>] [zeros]",
+			ErrSyntax,
+			strings.ToLower(tokens[0]))
+	}
+
+	return a.emit(a.text, func(int) (encoded, error) {
+		return encoded{
+			command: interpreter.PRINT,
+			fields:  []int{printType, options["decimals"]},
+			codes:   []int{int(value.id), options["width"], options["zeros"]},
+		}, nil
+	})
+}
+
+// input assembles "input <var>", which reads a line, and "input <var> line"
+// or "input <var> number".
+func (a *assembler) input(tokens []string) error {
+	if len(tokens) == 2 {
+		tokens = append(tokens, "line")
+	}
+
+	modes := map[string]interpreter.Y2KInputMode{
+		"line":   interpreter.Y2KInputLine,
+		"number": interpreter.Y2KInputNumber,
+	}
+
+	mode, ok := modes[strings.ToLower(tokens[len(tokens)-1])]
+	if len(tokens) != 3 || !ok {
+		return fmt.Errorf("%w: expected input <var> [line|number]", ErrSyntax)
+	}
+
+	target, err := parseLiteral(tokens[1])
+	if err != nil || target.kind != litVar {
+		return fmt.Errorf("%w: expected a variable to read into, not %q", ErrSyntax, tokens[1])
+	}
+
+	id, _ := interpreter.INPUT.ExtendedID()
+	return a.emit(a.text, func(int) (encoded, error) {
+		return encoded{
+			command: interpreter.EXTEND,
+			fields:  []int{id, int(target.id), int(mode)},
+			noValue: true,
+		}, nil
+	})
+}
+
+// list assembles "list <list> <op> ...", which performs an operation on a
+// list variable:
+//
+//	list v1 append v2
+//	list v1 get <index> v2
+//	list v1 set <index> v2
+//	list v1 length v2
+//	list v1 remove <index> v2
+//	list v1 sort
+//
+// The index can be a number or a variable.
+func (a *assembler) list(tokens []string) error {
+	if len(tokens) < 3 {
+		return fmt.Errorf("%w: expected list <var> <op> ...", ErrSyntax)
+	}
+
+	target, err := parseLiteral(tokens[1])
+	if err != nil || target.kind != litVar {
+		return fmt.Errorf("%w: expected a list variable, not %q", ErrSyntax, tokens[1])
+	}
+
+	listFn, ok := lookupOp(listOps, strings.ToLower(tokens[2]))
+	if !ok {
+		return fmt.Errorf("%w: unknown list operation %q", ErrSyntax, tokens[2])
+	}
+
+	// Operations that use an index have it before the variable
+	args := tokens[3:]
+	index := literal{kind: litInt, text: "0"}
+	switch interpreter.Y2KListFn(listFn) {
+	case interpreter.Y2KListGet, interpreter.Y2KListSet, interpreter.Y2KListRemove:
+		if len(args) != 2 {
+			return fmt.Errorf("%w: expected list <var> %s <index> <var>", ErrSyntax, tokens[2])
+		}
+
+		index, err = parseLiteral(args[0])
+		if err != nil || (index.kind != litInt && index.kind != litVar) {
+			return fmt.Errorf("%w: invalid index %q", ErrSyntax, args[0])
+		}
+		args = args[1:]
+	case interpreter.Y2KListSort:
+		args = append(args, "v0")
+	}
+
+	if len(args) != 1 {
+		return fmt.Errorf("%w: expected list <var> %s <var>", ErrSyntax, tokens[2])
+	}
+
+	value, err := parseLiteral(args[0])
+	if err != nil || value.kind != litVar {
+		return fmt.Errorf("%w: expected a variable, not %q", ErrSyntax, args[0])
+	}
+
+	id, _ := interpreter.LIST.ExtendedID()
+	return a.emit(a.text, func(digits int) (encoded, error) {
+		enc, err := encodeArg(index, digits, a.unicode)
+		enc.command = interpreter.EXTEND
+		enc.fields = append([]int{id, int(target.id), listFn, int(value.id)}, enc.fields...)
+		return enc, err
+	})
+}
+
+// str assembles "string <var> <op> ...", which reads from the text of a
+// variable and stores the result in another variable:
+//
+//	string v1 length v2
+//	string v1 from <index> v2
+//	string v1 to <index> v2
+//	string v1 find <string> v2
+//	string v1 charcode <index> v2
+//	string v1 reverse v2
+//	string v1 upper v2
+//	string v1 lower v2
+//	string v1 split <string> v2
+//	string v1 join <string> v2
+//
+// Indexes and strings can also be variables.
+func (a *assembler) str(tokens []string) error {
+	if len(tokens) < 4 {
+		return fmt.Errorf("%w: expected string <var> <op> ... <var>", ErrSyntax)
+	}
+
+	source, err := parseLiteral(tokens[1])
+	if err != nil || source.kind != litVar {
+		return fmt.Errorf("%w: expected a variable, not %q", ErrSyntax, tokens[1])
+	}
+
+	strFn, ok := lookupOp(strOps, strings.ToLower(tokens[2]))
+	if !ok {
+		return fmt.Errorf("%w: unknown string operation %q", ErrSyntax, tokens[2])
+	}
+
+	// Operations that use an argument have it before the variable
+	args := tokens[3:]
+	arg := literal{kind: litInt, text: "0"}
+	if kind, ok := strArgs[interpreter.Y2KStrFn(strFn)]; ok {
+		if len(args) != 2 {
+			return fmt.Errorf("%w: expected string <var> %s <value> <var>", ErrSyntax, tokens[2])
+		}
+
+		arg, err = parseLiteral(args[0])
+		if err != nil || (arg.kind != kind && arg.kind != litVar) {
+			return fmt.Errorf("%w: invalid argument %q for %s", ErrSyntax, args[0], tokens[2])
+		}
+		args = args[1:]
+	}
+
+	if len(args) != 1 {
+		return fmt.Errorf("%w: expected string <var> %s <var>", ErrSyntax, tokens[2])
+	}
+
+	dest, err := parseLiteral(args[0])
+	if err != nil || dest.kind != litVar {
+		return fmt.Errorf("%w: expected a variable, not %q", ErrSyntax, args[0])
+	}
+
+	id, _ := interpreter.STRING.ExtendedID()
+	return a.emit(a.text, func(digits int) (encoded, error) {
+		enc, err := encodeArg(arg, digits, a.unicode)
+		enc.command = interpreter.EXTEND
+		enc.fields = append([]int{id, int(source.id), strFn, int(dest.id)}, enc.fields...)
+		return enc, err
+	})
+}
+
+// convert assembles "convert <var> <type> <var>", which converts the first
+// variable to string, int, float, round (an int rounded to the nearest whole
+// number), char (the character with a character code) or code (the
+// character code of a character), and stores it in the second variable.
+// Without the second variable, the first variable is converted in place.
+func (a *assembler) convert(tokens []string) error {
+	if len(tokens) == 3 {
+		tokens = append(tokens, tokens[1])
+	}
+
+	if len(tokens) != 4 {
+		return fmt.Errorf("%w: expected convert <var> <type> [<var>]", ErrSyntax)
+	}
+
+	source, err := parseLiteral(tokens[1])
+	if err != nil || source.kind != litVar {
+		return fmt.Errorf("%w: expected a variable, not %q", ErrSyntax, tokens[1])
+	}
+
+	convFn, ok := lookupOp(convOps, strings.ToLower(tokens[2]))
+	if !ok {
+		return fmt.Errorf("%w: unknown conversion %q", ErrSyntax, tokens[2])
+	}
+
+	dest, err := parseLiteral(tokens[3])
+	if err != nil || dest.kind != litVar {
+		return fmt.Errorf("%w: expected a variable, not %q", ErrSyntax, tokens[3])
+	}
+
+	id, _ := interpreter.CONVERT.ExtendedID()
+	return a.emit(a.text, func(int) (encoded, error) {
+		return encoded{
+			command: interpreter.EXTEND,
+			fields:  []int{id, int(source.id), convFn, int(dest.id)},
+			noValue: true,
+		}, nil
+	})
+}
+
+// modify assembles "<var> <op> <value>", where op is one of the MODIFY
+// functions (i.e. "+="). Functions that don't need an argument can be
+// written as "<var> <op>" (i.e. "v1 abs").
+func (a *assembler) modify(tokens []string) error {
+	if len(tokens) == 2 && unaryOps[strings.ToLower(tokens[1])] {
+		tokens = append(tokens, "0")
+	}
+
+	if len(tokens) != 3 {
+		return fmt.Errorf("%w: expected <var> <op> <value>", ErrSyntax)
+	}
+
+	target, err := parseLiteral(tokens[0])
+	if err != nil || target.kind != litVar {
+		return fmt.Errorf("%w: unknown statement %q", ErrSyntax, tokens[0])
+	}
+
+	modFn, ok := lookupOp(modOps, strings.ToLower(tokens[1]))
+	if !ok {
+		return fmt.Errorf("%w: unknown operator %q", ErrSyntax, tokens[1])
+	}
+
+	value, err := parseLiteral(tokens[2])
+	if err != nil {
+		return err
+	}
+
+	if value.kind == litFloat {
+		return fmt.Errorf("%w: only whole numbers can be used with %s",
+			ErrUnencodable,
+			tokens[1])
+	}
+
+	return a.emit(a.text, func(digits int) (encoded, error) {
+		enc, err := encodeArg(value, digits, a.unicode)
+		enc.command = interpreter.MODIFY
+		enc.fields = append([]int{int(target.id), modFn}, enc.fields...)
+		return enc, err
+	})
+}
+
+// asmComparison is one of the comparisons of an if or while statement.
+type asmComparison struct {
+	logic  int
+	target uint8
+	compFn int
+	flags  int
+	value  literal
+	text   string
+}
+
+// condition assembles "if <comparison> {" and "while <comparison> {", where
+// the comparison is "<var> <op> <value>" or "<var> % <value> == 0" (or "!=
+// 0") for divisibility. Comparisons can be negated with "not", and joined
+// with "and" or "or", which are written as CLAUSE commands after the
+// CONDITION command.
+func (a *assembler) condition(tokens []string) error {
+	keyword := strings.ToLower(tokens[0])
+	flags := 0
+	term := utils.CondTerm
+	if keyword == "while" {
+		flags = interpreter.CondFlagLoop
+		term = utils.LoopTerm
+	}
+
+	if tokens[len(tokens)-1] != "{" {
+		return fmt.Errorf("%w: expected { at the end of %s", ErrSyntax, keyword)
+	}
+
+	var comps []asmComparison
+	logic := 0
+	start := 1
+	for i := 1; i < len(tokens); i++ {
+		next := clauseOps[strings.ToLower(tokens[i])]
+		if next == 0 && tokens[i] != "{" {
+			continue
+		}
+
+		comp, err := parseComparison(keyword, tokens[start:i])
+		if err != nil {
+			return err
+		}
+
+		if logic != 0 {
+			comp.text = strings.ToLower(tokens[start-1]) + " " + comp.text
+		}
+
+		comp.logic = logic
+		comps = append(comps, comp)
+		logic = next
+		start = i + 1
+	}
+
+	clauseID, _ := interpreter.CLAUSE.ExtendedID()
+	err := a.emitGroup(func(digits int) ([]encoded, error) {
+		var group []encoded
+		for i, comp := range comps {
+			enc, err := encodeArg(comp.value, digits, a.unicode)
+			if err != nil {
+				return nil, err
+			}
+
+			fields := []int{int(comp.target), comp.compFn, comp.flags, enc.fields[1]}
+			if i == 0 {
+				enc.command = interpreter.CONDITION
+				fields[2] += flags
+			} else {
+				enc.command = interpreter.EXTEND
+				fields = append([]int{clauseID, comp.logic}, fields...)
+			}
+
+			enc.fields = fields
+			enc.comment = comp.text
+			group = append(group, enc)
+		}
+
+		// The first line keeps the full statement as its comment
+		group[0].comment = a.text
+		return group, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.blocks = append(a.blocks, asmBlock{
+		term:    term,
+		line:    a.lineNum,
+		base:    a.base,
+		digits:  a.digits,
+		debug:   a.debug,
+		unicode: a.unicode,
+		stderr:  a.stderr,
+	})
+
+	return nil
+}
+
+// parseComparison reads a single comparison of an if or while statement,
+// which may start with "not".
+func parseComparison(keyword string, tokens []string) (asmComparison, error) {
+	comp := asmComparison{text: strings.Join(tokens, " ")}
+	if len(tokens) > 0 && strings.ToLower(tokens[0]) == "not" {
+		comp.flags += interpreter.CondFlagNegate
+		tokens = tokens[1:]
+	}
+
+	// Divisibility is the same comparison as checking if a string contains
+	// a value
+	if len(tokens) == 5 && tokens[1] == "%" && tokens[4] == "0" {
+		switch tokens[3] {
+		case "==":
+			tokens = []string{tokens[0], "contains", tokens[2]}
+		case "!=":
+			tokens = []string{tokens[0], "!contains", tokens[2]}
+		}
+	}
+
+	if len(tokens) != 3 {
+		return comp, fmt.Errorf("%w: expected %s <var> <op> <value> {", ErrSyntax, keyword)
+	}
+
+	target, err := parseLiteral(tokens[0])
+	if err != nil || target.kind != litVar {
+		return comp, fmt.Errorf("%w: expected a variable to compare, not %q", ErrSyntax, tokens[0])
+	}
+	comp.target = target.id
+
+	compFn, ok := lookupOp(compOps, tokens[1])
+	if !ok {
+		return comp, fmt.Errorf("%w: unknown comparison %q", ErrSyntax, tokens[1])
+	}
+	comp.compFn = compFn
+
+	comp.value, err = parseLiteral(tokens[2])
+	if err != nil {
+		return comp, err
+	}
+
+	if comp.value.kind == litFloat {
+		return comp, fmt.Errorf("%w: only whole numbers can be compared", ErrUnencodable)
+	} else if comp.value.kind == litVar {
+		comp.flags += interpreter.CondFlagIsVar
+	}
+
+	return comp, nil
+}
+
+// closeBlock writes the terminator of the innermost block.
+func (a *assembler) closeBlock() error {
+	if len(a.blocks) == 0 {
+		return fmt.Errorf("%w: unexpected }", ErrSyntax)
+	}
+
+	top := a.blocks[len(a.blocks)-1]
+	a.blocks = a.blocks[:len(a.blocks)-1]
+
+	a.write(top.term, "}")
+
+	// Changes made by META commands only apply until the end of the block
+	a.base = top.base
+	a.digits = top.digits
+	a.debug = top.debug
+	a.unicode = top.unicode
+	a.stderr = top.stderr
+
+	return nil
+}
+
+// elseBranch assembles "} else {", which writes the else marker of the
+// innermost if block. The else branch starts with the same number of digits
+// as the if branch, since only one of them is run.
+func (a *assembler) elseBranch() error {
+	if len(a.blocks) == 0 {
+		return fmt.Errorf("%w: unexpected }", ErrSyntax)
+	}
+
+	top := a.blocks[len(a.blocks)-1]
+	if top.term != utils.CondTerm || top.hasElse || top.define {
+		return fmt.Errorf("%w: else can only be used once, after an if block", ErrSyntax)
+	}
+
+	// The marker is written at the same depth as the if statement
+	a.blocks = a.blocks[:len(a.blocks)-1]
+	a.write(utils.ElseMarker, a.text)
+
+	top.hasElse = true
+	a.blocks = append(a.blocks, top)
+	a.base = top.base
+	a.digits = top.digits
+	a.debug = top.debug
+	a.unicode = top.unicode
+	a.stderr = top.stderr
+
+	return nil
+}
+
+// breakLoop assembles "break", which exits the innermost while loop.
+func (a *assembler) breakLoop(tokens []string) error {
+	if len(tokens) > 1 {
+		return fmt.Errorf("%w: unexpected %s after break", ErrSyntax, tokens[1])
+	}
+
+	// Loops outside of a subroutine can't be exited from within it
+	inLoop := false
+	for _, block := range a.blocks {
+		inLoop = (inLoop || block.term == utils.LoopTerm) && !block.define
+	}
+
+	if !inLoop {
+		return fmt.Errorf("%w: break can only be used inside of a while loop", ErrSyntax)
+	}
+
+	id, _ := interpreter.BREAK.ExtendedID()
+	return a.emit(a.text, func(int) (encoded, error) {
+		return encoded{command: interpreter.EXTEND, fields: []int{id}, noValue: true}, nil
+	})
+}
+
+// define assembles "sub <n> {", which starts the body of subroutine n.
+func (a *assembler) define(tokens []string) error {
+	if len(tokens) != 3 || tokens[2] != "{" {
+		return fmt.Errorf("%w: expected sub <n> {", ErrSyntax)
+	}
+
+	sub, err := strconv.Atoi(tokens[1])
+	if err != nil || sub < 0 {
+		return fmt.Errorf("%w: invalid subroutine number %q", ErrSyntax, tokens[1])
+	}
+
+	id, _ := interpreter.DEFINE.ExtendedID()
+	err = a.emit(a.text, func(int) (encoded, error) {
+		return encoded{command: interpreter.EXTEND, fields: []int{id, sub}, noValue: true}, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.blocks = append(a.blocks, asmBlock{
+		term:    utils.CondTerm,
+		line:    a.lineNum,
+		base:    a.base,
+		digits:  a.digits,
+		debug:   a.debug,
+		unicode: a.unicode,
+		stderr:  a.stderr,
+		define:  true,
+	})
+
+	return nil
+}
+
+// call assembles "call <n>", which runs subroutine n.
+func (a *assembler) call(tokens []string) error {
+	if len(tokens) != 2 {
+		return fmt.Errorf("%w: expected call <n>", ErrSyntax)
+	}
+
+	sub, err := strconv.Atoi(tokens[1])
+	if err != nil || sub < 0 {
+		return fmt.Errorf("%w: invalid subroutine number %q", ErrSyntax, tokens[1])
+	}
+
+	id, _ := interpreter.CALL.ExtendedID()
+	return a.emit(a.text, func(int) (encoded, error) {
+		return encoded{command: interpreter.EXTEND, fields: []int{id, sub}, noValue: true}, nil
+	})
+}
+
+// returnSub assembles "return", which exits the subroutine that is running.
+func (a *assembler) returnSub(tokens []string) error {
+	if len(tokens) > 1 {
+		return fmt.Errorf("%w: unexpected %s after return", ErrSyntax, tokens[1])
+	}
+
+	inSub := false
+	for _, block := range a.blocks {
+		inSub = inSub || block.define
+	}
+
+	if !inSub {
+		return fmt.Errorf("%w: return can only be used inside of a subroutine", ErrSyntax)
+	}
+
+	id, _ := interpreter.RETURN.ExtendedID()
+	return a.emit(a.text, func(int) (encoded, error) {
+		return encoded{command: interpreter.EXTEND, fields: []int{id}, noValue: true}, nil
+	})
+}
+
+// exit assembles "exit" and "exit <code>", which end the program with a
+// status code (0 if it isn't given). The code can also be a variable.
+func (a *assembler) exit(tokens []string) error {
+	if len(tokens) > 2 {
+		return fmt.Errorf("%w: expected exit [<code>]", ErrSyntax)
+	}
+
+	code := literal{kind: litInt, text: "0"}
+	if len(tokens) == 2 {
+		var err error
+		code, err = parseLiteral(tokens[1])
+		if err != nil || (code.kind != litInt && code.kind != litVar) {
+			return fmt.Errorf("%w: invalid exit code %q", ErrSyntax, tokens[1])
+		}
+	}
+
+	if code.kind == litInt {
+		n, err := strconv.Atoi(code.text)
+		if err != nil || n < 0 || n > interpreter.MaxExitCode {
+			return fmt.Errorf("%w: exit code must be from 0 to %d", ErrSyntax, interpreter.MaxExitCode)
+		}
+	}
+
+	id, _ := interpreter.EXIT.ExtendedID()
+	return a.emit(a.text, func(digits int) (encoded, error) {
+		enc, err := encodeArg(code, digits, a.unicode)
+		enc.command = interpreter.EXTEND
+		enc.fields = append([]int{id}, enc.fields...)
+		return enc, err
+	})
+}
+
+// setDigits assembles "digits <n>", which changes the number of digits
+// parsed at a time for the rest of the current block.
+func (a *assembler) setDigits(tokens []string) error {
+	if len(tokens) != 2 {
+		return fmt.Errorf("%w: expected digits <n>", ErrSyntax)
+	}
+
+	digits, err := strconv.Atoi(tokens[1])
+	if err != nil || digits < 1 {
+		return fmt.Errorf("%w: invalid number of digits %q", ErrSyntax, tokens[1])
+	}
+
+	a.base = digits
+	return a.meta(digits, a.debug, a.unicode, a.stderr, a.text)
+}
+
+// setDebug assembles "debug on" and "debug off", which turn debug mode on or
+// off for the rest of the current block.
+func (a *assembler) setDebug(tokens []string) error {
+	if len(tokens) != 2 || (tokens[1] != "on" && tokens[1] != "off") {
+		return fmt.Errorf("%w: expected debug on|off", ErrSyntax)
+	}
+
+	return a.meta(a.digits, tokens[1] == "on", a.unicode, a.stderr, a.text)
+}
+
+// setCharset assembles "charset unicode" and "charset printable", which
+// change how the character codes of strings are written for the rest of
+// the current block. Unicode code points can be used for any character
+// (such as a newline), but most need more digits than the characters of
+// utils.Printable.
+func (a *assembler) setCharset(tokens []string) error {
+	if len(tokens) != 2 || (tokens[1] != "unicode" && tokens[1] != "printable") {
+		return fmt.Errorf("%w: expected charset unicode|printable", ErrSyntax)
+	}
+
+	return a.meta(a.digits, a.debug, tokens[1] == "unicode", a.stderr, a.text)
+}
+
+// setOutput assembles "output stderr" and "output stdout", which change
+// where print statements write to for the rest of the current block.
+func (a *assembler) setOutput(tokens []string) error {
+	if len(tokens) != 2 || (tokens[1] != "stderr" && tokens[1] != "stdout") {
+		return fmt.Errorf("%w: expected output stderr|stdout", ErrSyntax)
+	}
+
+	return a.meta(a.digits, a.debug, a.unicode, tokens[1] == "stderr", a.text)
+}
+
+// meta writes a META command using the current number of digits.
+func (a *assembler) meta(digits int, debug bool, unicode bool, stderr bool, comment string) error {
+	flags := 0
+	if debug {
+		flags += interpreter.MetaFlagDebug
+	}
+	if unicode {
+		flags += interpreter.MetaFlagUnicode
+	}
+	if stderr {
+		flags += interpreter.MetaFlagStderr
+	}
+
+	enc := encoded{
+		command: interpreter.META,
+		fields:  []int{flags, digits},
+		noValue: true,
+	}
+	if !enc.fits(a.digits) {
+		return fmt.Errorf("%w: can't switch to %d digits from %d digits",
+			ErrUnencodable,
+			digits,
+			a.digits)
+	}
+
+	a.write(enc.digits(a.digits), comment)
+	a.digits = digits
+	a.debug = debug
+	a.unicode = unicode
+	a.stderr = stderr
+
+	return nil
+}
+
+// emit encodes an instruction using the current number of digits. If the
+// instruction doesn't fit, the fewest digits (but not fewer than the base
+// number of digits) that can hold all of its values are used instead, and a
+// META command is written first to switch to them.
+func (a *assembler) emit(comment string, build func(digits int) (encoded, error)) error {
+	return a.emitGroup(func(digits int) ([]encoded, error) {
+		enc, err := build(digits)
+		enc.comment = comment
+		return []encoded{enc}, err
+	})
+}
+
+// emitGroup encodes a group of instructions in the same way as emit. All of
+// the instructions use the same number of digits, so that no META command
+// is needed between them.
+func (a *assembler) emitGroup(build func(digits int) ([]encoded, error)) error {
+	group, err := build(a.digits)
+	if err != nil {
+		return err
+	} else if fitsAll(group, a.digits) {
+		a.writeGroup(group, a.digits)
+		return nil
+	}
+
+	for digits := a.base; digits <= maxDigits; digits++ {
+		group, err := build(digits)
+		if err != nil {
+			return err
+		}
+
+		if !fitsAll(group, digits) {
+			continue
+		}
+
+		if digits != a.digits {
+			err = a.meta(digits, a.debug, a.unicode, a.stderr, fmt.Sprintf("digits %d (automatic)", digits))
+			if err != nil {
+				return err
+			}
+		}
+
+		a.writeGroup(group, digits)
+		return nil
+	}
+
+	return fmt.Errorf("%w: values are too large", ErrUnencodable)
+}
+
+// writeGroup writes the digits of a group of instructions.
+func (a *assembler) writeGroup(group []encoded, digits int) {
+	for _, enc := range group {
+		a.write(enc.digits(digits), enc.comment)
+	}
+}
+
+// fitsAll checks if every instruction of a group fits in the given number
+// of digits.
+func fitsAll(group []encoded, digits int) bool {
+	for _, enc := range group {
+		if !enc.fits(digits) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// write adds the digits of an instruction to the output.
+func (a *assembler) write(digits string, comment string) {
+	a.lines = append(a.lines, outputLine{
+		digits:  strings.Repeat(indent, len(a.blocks)) + digits,
+		comment: comment,
+		where:   fmt.Sprintf("line %d", a.lineNum),
+	})
+
+	a.timestamp.WriteString(strings.ReplaceAll(digits, " ", ""))
+}
+
+// fits checks if all fields and codes of an instruction can be written with
+// the given number of digits.
+func (enc encoded) fits(digits int) bool {
+	limit := 1
+	for i := 0; i < digits; i++ {
+		limit *= 10
+	}
+
+	if int(enc.command) >= limit {
+		return false
+	}
+
+	for _, val := range append(enc.fields, enc.codes...) {
+		if val >= limit {
+			return false
+		}
+	}
+
+	return true
+}
+
+// digits returns the digits of an instruction, grouped in the same way as
+// the disassembler's output.
+func (enc encoded) digits(digits int) string {
+	header := fmt.Sprintf("%0*d", digits, enc.command)
+	for _, field := range enc.fields {
+		header += fmt.Sprintf("%0*d", digits, field)
+	}
+
+	if enc.noValue {
+		return groupDigits(header, digits)
+	}
+
+	value := enc.number
+	for _, code := range enc.codes {
+		value += fmt.Sprintf("%0*d", digits, code)
+	}
+
+	// At least one chunk of the value is always read, and numbers are
+	// padded with trailing zeros (which are ignored) to fill the last chunk.
+	for len(value) == 0 || len(value)%digits != 0 {
+		value += "0"
+	}
+
+	return groupDigits(header, digits) + " " + groupDigits(value, digits)
+}
+
+// encodeArg encodes the argument of a MODIFY or CONDITION command, returning
+// the ArgIsVar and size fields along with the value.
+func encodeArg(value literal, digits int, unicode bool) (encoded, error) {
+	enc := encoded{}
+
+	switch value.kind {
+	case litString:
+		codes, err := encodeString(value.text, unicode)
+		if err != nil {
+			return enc, err
+		}
+
+		enc.codes = codes
+		enc.fields = []int{0, len(codes) * digits}
+	case litVar:
+		enc.number = strconv.Itoa(int(value.id))
+		enc.fields = []int{1, len(enc.number)}
+	default:
+		enc.number = value.text
+		enc.fields = []int{0, len(enc.number)}
+	}
+
+	return enc, checkSize(enc.fields[1])
+}
+
+// checkSize returns an error if a value is too long for a size field.
+func checkSize(size int) error {
+	if size > maxSize {
+		return fmt.Errorf("%w: value is longer than %d digits", ErrUnencodable, maxSize)
+	}
+
+	return nil
+}
+
+// encodeString converts a string to character codes, which are Unicode code
+// points if unicode is true, or indexes of utils.Printable otherwise.
+func encodeString(str string, unicode bool) ([]int, error) {
+	codes := make([]int, 0, len(str))
+	for _, c := range str {
+		code := utils.CharToCode(c, unicode)
+		if code < 0 {
+			return nil, fmt.Errorf(
+				"%w: no character code for %q (use \"charset unicode\" first)",
+				ErrUnencodable,
+				c)
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// parseLiteral reads a literal value, which is either a quoted string, a
+// variable (i.e. "v1"), or a non-negative number.
+func parseLiteral(token string) (literal, error) {
+	if strings.HasPrefix(token, "\"") {
+		str, err := strconv.Unquote(token)
+		if err != nil {
+			return literal{}, fmt.Errorf("%w: invalid string %s", ErrSyntax, token)
+		}
+
+		return literal{kind: litString, text: str}, nil
+	}
+
+	if strings.HasPrefix(strings.ToLower(token), "v") {
+		id, err := parseID(token[1:])
+		return literal{kind: litVar, id: id}, err
+	}
+
+	if strings.HasPrefix(token, "-") {
+		return literal{}, fmt.Errorf(
+			"%w: negative numbers can't be written directly (subtract from 0 instead)",
+			ErrUnencodable)
+	}
+
+	kind := litInt
+	digits := token
+	if strings.Count(token, ".") == 1 {
+		kind = litFloat
+		digits = strings.Replace(token, ".", "", 1)
+	}
+
+	if len(digits) == 0 || strings.Trim(digits, "0123456789") != "" {
+		return literal{}, fmt.Errorf("%w: invalid value %q", ErrSyntax, token)
+	}
+
+	return literal{kind: kind, text: token}, nil
+}
+
+// parseID reads a variable ID.
+func parseID(token string) (uint8, error) {
+	id, ok := interpreter.ParseVarID(token)
+	if !ok {
+		return 0, fmt.Errorf("%w: invalid variable ID %q", ErrSyntax, token)
+	}
+
+	return id, nil
+}
+
+// lookupOp finds the ID of an operator in a map of IDs to mnemonics.
+func lookupOp(ops map[int]string, op string) (int, bool) {
+	for id, mnemonic := range ops {
+		if mnemonic == op {
+			return id, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,559 @@
+// Package asm converts Y2K programs to and from a readable assembly language
+// of mnemonics, such as "CREATE v1 int size=4" or "PRINT var v1". It's used
+// by the "y2k disasm" and "y2k asm" commands.
+package asm
+
+import (
+	"errors"
+	"fmt"
+	"github.com/benbusby/y2k/src/interpreter"
+	"github.com/benbusby/y2k/src/utils"
+	"io"
+	"math/big"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// modOps holds the mnemonic for each MODIFY function.
+var modOps = map[int]string{
+	int(interpreter.Y2KModAdd):       "+=",
+	int(interpreter.Y2KModSubtract):  "-=",
+	int(interpreter.Y2KModMultiply):  "*=",
+	int(interpreter.Y2KModDivide):    "/=",
+	int(interpreter.Y2KModPow):       "**=",
+	int(interpreter.Y2KModMod):       "%=",
+	int(interpreter.Y2KModIntDivide): "//=",
+	int(interpreter.Y2KModRemainder): "rem=",
+	int(interpreter.Y2KModSet):       "=",
+	int(interpreter.Y2KModAbs):       "abs",
+	int(interpreter.Y2KModFloor):     "floor",
+	int(interpreter.Y2KModCeil):      "ceil",
+	int(interpreter.Y2KModRound):     "round",
+	int(interpreter.Y2KModMin):       "min=",
+	int(interpreter.Y2KModMax):       "max=",
+}
+
+// unaryOps are the MODIFY functions that don't need an argument. Rounding
+// functions can still be given the # of decimal places to keep.
+var unaryOps = map[string]bool{
+	"abs":   true,
+	"floor": true,
+	"ceil":  true,
+	"round": true,
+}
+
+// compOps holds the mnemonic for each CONDITION comparison. Divisibility
+// (which checks if a string contains a value) is written as "v1 % 3 == 0"
+// or "v1 % 3 != 0" for numbers.
+var compOps = map[int]string{
+	int(interpreter.Y2KCompEqual):          "==",
+	int(interpreter.Y2KCompLess):           "<",
+	int(interpreter.Y2KCompGreater):        ">",
+	int(interpreter.Y2KCompDivisible):      "contains",
+	int(interpreter.Y2KCompNotEqual):       "!=",
+	int(interpreter.Y2KCompLessOrEqual):    "<=",
+	int(interpreter.Y2KCompGreaterOrEqual): ">=",
+	int(interpreter.Y2KCompNotDivisible):   "!contains",
+	int(interpreter.Y2KCompStartsWith):     "startswith",
+}
+
+// listOps holds the mnemonic for each LIST operation.
+var listOps = map[int]string{
+	int(interpreter.Y2KListAppend): "append",
+	int(interpreter.Y2KListGet):    "get",
+	int(interpreter.Y2KListSet):    "set",
+	int(interpreter.Y2KListLength): "length",
+	int(interpreter.Y2KListRemove): "remove",
+	int(interpreter.Y2KListSort):   "sort",
+}
+
+// strOps holds the mnemonic for each STRING operation.
+var strOps = map[int]string{
+	int(interpreter.Y2KStrLength):   "length",
+	int(interpreter.Y2KStrFrom):     "from",
+	int(interpreter.Y2KStrTo):       "to",
+	int(interpreter.Y2KStrFind):     "find",
+	int(interpreter.Y2KStrCharCode): "charcode",
+	int(interpreter.Y2KStrReverse):  "reverse",
+	int(interpreter.Y2KStrUpper):    "upper",
+	int(interpreter.Y2KStrLower):    "lower",
+	int(interpreter.Y2KStrSplit):    "split",
+	int(interpreter.Y2KStrJoin):     "join",
+}
+
+// strArgs holds the kind of argument that each STRING operation uses, if
+// it uses one.
+var strArgs = map[interpreter.Y2KStrFn]literalKind{
+	interpreter.Y2KStrFrom:     litInt,
+	interpreter.Y2KStrTo:       litInt,
+	interpreter.Y2KStrFind:     litString,
+	interpreter.Y2KStrCharCode: litInt,
+	interpreter.Y2KStrSplit:    litString,
+	interpreter.Y2KStrJoin:     litString,
+}
+
+// convOps holds the mnemonic for each CONVERT conversion.
+var convOps = map[int]string{
+	int(interpreter.Y2KConvString): "string",
+	int(interpreter.Y2KConvInt):    "int",
+	int(interpreter.Y2KConvFloat):  "float",
+	int(interpreter.Y2KConvRound):  "round",
+	int(interpreter.Y2KConvChar):   "char",
+	int(interpreter.Y2KConvCode):   "code",
+}
+
+// convTypes holds the type of the variable that each CONVERT conversion
+// results in.
+var convTypes = map[interpreter.Y2KConvFn]interpreter.Y2KVarType{
+	interpreter.Y2KConvString: interpreter.Y2KString,
+	interpreter.Y2KConvInt:    interpreter.Y2KInt,
+	interpreter.Y2KConvFloat:  interpreter.Y2KFloat,
+	interpreter.Y2KConvRound:  interpreter.Y2KInt,
+	interpreter.Y2KConvChar:   interpreter.Y2KString,
+	interpreter.Y2KConvCode:   interpreter.Y2KInt,
+}
+
+// clauseOps holds the Logic value of a CLAUSE command for each keyword that
+// joins the comparisons of a condition.
+var clauseOps = map[string]int{
+	"and": interpreter.ClauseAnd,
+	"or":  interpreter.ClauseOr,
+}
+
+// disassembler tracks the data types of variables while disassembling, so
+// that values for string variables can be shown as strings.
+type disassembler struct {
+	y2k       *interpreter.Y2K
+	timestamp string
+	types     map[uint8]interpreter.Y2KVarType
+	lines     []outputLine
+}
+
+// Disassemble decodes a timestamp in the same way that the interpreter
+// would, and writes it as a commented raw Y2K file with one instruction per
+// line. Each line shows the original digits, a mnemonic for the instruction,
+// and the offset and position that the digits were read from. Because the
+// mnemonics are written as comments, the output can be run (or exported)
+// in the same way as the original program.
+func Disassemble(w io.Writer, y2k *interpreter.Y2K, timestamp string) error {
+	program, err := y2k.Compile(timestamp)
+	if err != nil {
+		return err
+	}
+
+	d := &disassembler{
+		y2k:       y2k,
+		timestamp: timestamp,
+		types:     map[uint8]interpreter.Y2KVarType{},
+	}
+
+	end := 0
+	depth := 0
+	for i := range program.Instructions {
+		ins := &program.Instructions[i]
+
+		// Digits at the end of a block that are too short to be a
+		// command are never run
+		if ins.Offset > end {
+			d.unused(depth, end, ins.Offset)
+		}
+
+		switch ins.Op {
+		case interpreter.OpEnd:
+			depth--
+			d.add(depth, ins)
+		case interpreter.OpElse, interpreter.OpClause:
+			d.add(depth-1, ins)
+		default:
+			d.add(depth, ins)
+		}
+
+		if ins.Op == interpreter.OpCondition || ins.Op == interpreter.OpDefine {
+			depth++
+		}
+
+		if ins.Offset+ins.Size > end {
+			end = ins.Offset + ins.Size
+		}
+	}
+
+	if end < len(timestamp) {
+		d.unused(0, end, len(timestamp))
+	}
+
+	header := ""
+	if y2k.Digits > 1 {
+		header = fmt.Sprintf("Run with -d %d", y2k.Digits)
+	}
+
+	return writeLines(w, header, d.lines)
+}
+
+// add appends the line for an instruction.
+func (d *disassembler) add(depth int, ins *interpreter.Instruction) {
+	// Block terminators and else markers are always 4 digits, regardless
+	// of the number of digits being parsed at a time
+	digits := ins.Value
+	if ins.Op != interpreter.OpEnd && ins.Op != interpreter.OpElse {
+		header := d.timestamp[ins.Offset : ins.Offset+ins.Size-len(ins.Value)]
+		digits = groupDigits(header, ins.Digits)
+		if len(ins.Value) > 0 {
+			digits += " " + groupDigits(ins.Value, ins.Digits)
+		}
+	}
+
+	d.lines = append(d.lines, outputLine{
+		digits:  strings.Repeat(indent, depth) + digits,
+		comment: d.mnemonic(ins),
+		where:   d.where(ins.Offset),
+	})
+}
+
+// unused appends a line for digits that aren't part of any instruction.
+func (d *disassembler) unused(depth int, start int, end int) {
+	d.lines = append(d.lines, outputLine{
+		digits:  strings.Repeat(indent, depth) + d.timestamp[start:end],
+		comment: "(unused)",
+		where:   d.where(start),
+	})
+}
+
+// where describes the offset and source position of a digit.
+func (d *disassembler) where(offset int) string {
+	where := fmt.Sprintf("offset %d", offset)
+
+	pos, ok := d.y2k.Source.Lookup(offset)
+	if !ok {
+		return where
+	}
+
+	if pos.Line > 0 {
+		return fmt.Sprintf("%s, line %d, column %d", where, pos.Line, pos.Column)
+	}
+
+	return fmt.Sprintf("%s, %s digit %d", where, filepath.Base(pos.File), pos.Digit)
+}
+
+// mnemonic describes what an instruction does.
+func (d *disassembler) mnemonic(ins *interpreter.Instruction) string {
+	switch ins.Op {
+	case interpreter.OpEnd:
+		return "END"
+	case interpreter.OpElse:
+		return "ELSE"
+	case interpreter.OpContinue:
+		return "CONTINUE"
+	case interpreter.OpBreak:
+		return "BREAK"
+	case interpreter.OpDefine:
+		return fmt.Sprintf("DEFINE sub %d", ins.Arg("SubID"))
+	case interpreter.OpCall:
+		return fmt.Sprintf("CALL sub %d", ins.Arg("SubID"))
+	case interpreter.OpReturn:
+		return "RETURN"
+	case interpreter.OpSkip:
+		return "NOP"
+	case interpreter.OpError:
+		var y2kErr *interpreter.Error
+		if errors.As(ins.Err, &y2kErr) {
+			return fmt.Sprintf("ERROR %s.%s: %s", y2kErr.Command, y2kErr.Field, y2kErr.Err)
+		}
+
+		return fmt.Sprintf("ERROR %s", ins.Err)
+	}
+
+	switch ins.Command {
+	case interpreter.PRINT:
+		return d.print(ins)
+	case interpreter.CREATE:
+		return d.create(ins)
+	case interpreter.MODIFY:
+		id := uint8(ins.Arg("VarID"))
+		arg := d.literal(id, ins.Value[:ins.Arg("ModSize")], ins)
+		if ins.Arg("ArgIsVar") != 0 {
+			arg = varName(ins.Value[:ins.Arg("ModSize")])
+		}
+
+		op := modOps[ins.Arg("ModFn")]
+		if unaryOps[op] && ins.Arg("ArgIsVar") == 0 && utils.StrArrToFloat(utils.SplitStrByN(ins.Value, ins.Digits)) == 0 {
+			return fmt.Sprintf("MODIFY v%d %s", id, op)
+		}
+
+		return fmt.Sprintf("MODIFY v%d %s %s", id, op, arg)
+	case interpreter.CONDITION:
+		keyword := "IF"
+		if ins.Arg("Flags")&interpreter.CondFlagLoop != 0 {
+			keyword = "WHILE"
+		}
+
+		return keyword + " " + d.comparison(ins)
+	case interpreter.CLAUSE:
+		for keyword, logic := range clauseOps {
+			if ins.Arg("Logic") == logic {
+				return strings.ToUpper(keyword) + " " + d.comparison(ins)
+			}
+		}
+	case interpreter.INPUT:
+		// The type of the variable depends on the input
+		id := uint8(ins.Arg("VarID"))
+		delete(d.types, id)
+
+		if interpreter.Y2KInputMode(ins.Arg("Mode")) == interpreter.Y2KInputNumber {
+			return fmt.Sprintf("INPUT v%d number", id)
+		}
+
+		return fmt.Sprintf("INPUT v%d line", id)
+	case interpreter.LIST:
+		return d.list(ins)
+	case interpreter.STRING:
+		return d.str(ins)
+	case interpreter.CONVERT:
+		convFn := interpreter.Y2KConvFn(ins.Arg("ConvFn"))
+		id := uint8(ins.Arg("DestID"))
+		d.types[id] = convTypes[convFn]
+
+		return fmt.Sprintf("CONVERT v%d %s v%d", ins.Arg("VarID"), convOps[int(convFn)], id)
+	case interpreter.EXIT:
+		value := ins.Value[:ins.Arg("CodeSize")]
+		if ins.Arg("CodeIsVar") != 0 {
+			return "EXIT " + varName(value)
+		}
+
+		return "EXIT " + utils.FloatToString(utils.StrArrToFloat(utils.SplitStrByN(value, ins.Digits)))
+	case interpreter.META:
+		meta := fmt.Sprintf("META digits=%d", ins.Arg("Digits"))
+		if ins.Arg("Flags")&interpreter.MetaFlagDebug != 0 {
+			meta += " debug"
+		}
+		if ins.Arg("Flags")&interpreter.MetaFlagUnicode != 0 {
+			meta += " unicode"
+		}
+		if ins.Arg("Flags")&interpreter.MetaFlagStderr != 0 {
+			meta += " stderr"
+		}
+
+		return meta
+	}
+
+	return ins.String()
+}
+
+// print describes a PRINT instruction, i.e. "PRINT var v1", "WRITE "text"",
+// "PRINT v1, v2 sep ","" or "PRINT v1 decimals 2 width 8 zeros". Types that
+// don't end with a newline are shown as WRITE.
+func (d *disassembler) print(ins *interpreter.Instruction) string {
+	printType := interpreter.Y2KPrintType(ins.Arg("Type"))
+	keyword := "PRINT"
+	for normal, inline := range inlineTypes {
+		if printType == inline {
+			keyword = "WRITE"
+			printType = normal
+		}
+	}
+
+	chunks := utils.SplitStrByN(ins.Value, ins.Digits)
+	switch printType {
+	case interpreter.Y2KPrintString:
+		value := strings.Join(chunks[:ins.Arg("Size")], "")
+		return keyword + " " + strconv.Quote(decodeString(value, ins))
+	case interpreter.Y2KPrintVars:
+		names := make([]string, ins.Arg("Size"))
+		for i, chunk := range chunks[1 : len(names)+1] {
+			names[i] = varName(chunk)
+		}
+
+		separator := strconv.Quote(decodeString(chunks[0], ins))
+		return fmt.Sprintf("%s %s sep %s", keyword, strings.Join(names, ", "), separator)
+	case interpreter.Y2KPrintNumber:
+		desc := keyword + " " + varName(chunks[0])
+		if decimals := ins.Arg("Size"); decimals > 0 {
+			desc += fmt.Sprintf(" decimals %d", decimals)
+		}
+		if width := utils.StrToInt(chunks[1]); width > 0 {
+			desc += fmt.Sprintf(" width %d", width)
+		}
+		if utils.StrToInt(chunks[2]) != 0 {
+			desc += " zeros"
+		}
+
+		return desc
+	}
+
+	return keyword + " var " + varName(ins.Value)
+}
+
+// list describes a LIST instruction, i.e. "LIST v1 get 3 v2".
+func (d *disassembler) list(ins *interpreter.Instruction) string {
+	listFn := interpreter.Y2KListFn(ins.Arg("ListFn"))
+	id := uint8(ins.Arg("VarID"))
+	desc := fmt.Sprintf("LIST v%d %s", ins.Arg("ListID"), listOps[int(listFn)])
+
+	switch listFn {
+	case interpreter.Y2KListSort:
+		return desc
+	case interpreter.Y2KListGet, interpreter.Y2KListSet, interpreter.Y2KListRemove:
+		value := ins.Value[:ins.Arg("IndexSize")]
+		index := utils.FloatToString(utils.StrArrToFloat(utils.SplitStrByN(value, ins.Digits)))
+		if ins.Arg("IndexIsVar") != 0 {
+			index = varName(value)
+		}
+
+		desc += " " + index
+	}
+
+	// The type of the variable depends on the list's items
+	if listFn != interpreter.Y2KListAppend && listFn != interpreter.Y2KListSet {
+		delete(d.types, id)
+		if listFn == interpreter.Y2KListLength {
+			d.types[id] = interpreter.Y2KInt
+		}
+	}
+
+	return fmt.Sprintf("%s v%d", desc, id)
+}
+
+// str describes a STRING instruction, i.e. "STRING v1 find "a" v2".
+func (d *disassembler) str(ins *interpreter.Instruction) string {
+	strFn := interpreter.Y2KStrFn(ins.Arg("StrFn"))
+	desc := fmt.Sprintf("STRING v%d %s", ins.Arg("VarID"), strOps[int(strFn)])
+
+	if kind, ok := strArgs[strFn]; ok {
+		value := ins.Value[:ins.Arg("ArgSize")]
+		switch {
+		case ins.Arg("ArgIsVar") != 0:
+			desc += " " + varName(value)
+		case kind == litString:
+			desc += " " + strconv.Quote(decodeString(value, ins))
+		default:
+			desc += " " + utils.FloatToString(utils.StrArrToFloat(utils.SplitStrByN(value, ins.Digits)))
+		}
+	}
+
+	id := uint8(ins.Arg("DestID"))
+	switch strFn {
+	case interpreter.Y2KStrLength, interpreter.Y2KStrFind, interpreter.Y2KStrCharCode:
+		d.types[id] = interpreter.Y2KInt
+	case interpreter.Y2KStrSplit:
+		d.types[id] = interpreter.Y2KList
+	default:
+		d.types[id] = interpreter.Y2KString
+	}
+
+	return fmt.Sprintf("%s v%d", desc, id)
+}
+
+// comparison describes the comparison of a CONDITION or CLAUSE instruction,
+// i.e. "v1 % 3 == 0" or "NOT v1 < v2".
+func (d *disassembler) comparison(ins *interpreter.Instruction) string {
+	not := ""
+	if ins.Arg("Flags")&interpreter.CondFlagNegate != 0 {
+		not = "NOT "
+	}
+
+	id := uint8(ins.Arg("VarID"))
+	comp := d.literal(id, ins.Value[:ins.Arg("CompValSize")], ins)
+	if ins.Arg("Flags")&interpreter.CondFlagIsVar != 0 {
+		comp = varName(ins.Value[:ins.Arg("CompValSize")])
+	}
+	if d.types[id] != interpreter.Y2KString {
+		switch interpreter.Y2KCompFn(ins.Arg("CompFn")) {
+		case interpreter.Y2KCompDivisible:
+			return fmt.Sprintf("%sv%d %% %s == 0", not, id, comp)
+		case interpreter.Y2KCompNotDivisible:
+			return fmt.Sprintf("%sv%d %% %s != 0", not, id, comp)
+		}
+	}
+
+	return fmt.Sprintf("%sv%d %s %s", not, id, compOps[ins.Arg("CompFn")], comp)
+}
+
+// create describes a CREATE instruction, and records the type of the new
+// variable.
+func (d *disassembler) create(ins *interpreter.Instruction) string {
+	id := uint8(ins.Arg("ID"))
+	size := ins.Arg("Size")
+	varType := interpreter.Y2KVarType(ins.Arg("Type"))
+	d.types[id] = varType
+
+	var value string
+	switch varType {
+	case interpreter.Y2KString:
+		chunks := utils.SplitStrByN(ins.Value, ins.Digits)
+		value = strconv.Quote(decodeString(strings.Join(chunks[:size], ""), ins))
+	case interpreter.Y2KVarCopy:
+		source := ins.Value[:size]
+		if sourceID, ok := interpreter.ParseVarID(source); ok {
+			d.types[id] = d.types[sourceID]
+		}
+
+		return fmt.Sprintf("CREATE v%d copy %s", id, varName(source))
+	case interpreter.Y2KList:
+		items := make([]string, size)
+		for i, chunk := range utils.SplitStrByN(ins.Value, ins.Digits)[:size] {
+			items[i] = varName(chunk)
+		}
+
+		return fmt.Sprintf("CREATE v%d list [%s]", id, strings.Join(items, ", "))
+	case interpreter.Y2KArgs:
+		d.types[id] = interpreter.Y2KList
+		return fmt.Sprintf("CREATE v%d args", id)
+	case interpreter.Y2KFloat:
+		value = strconv.Quote(decodeFloat(ins.Value[:size]))
+	default:
+		value = strconv.Quote(ins.Value[:size])
+	}
+
+	return fmt.Sprintf("CREATE v%d %s size=%d %s", id, varType, size, value)
+}
+
+// literal describes a value that's used with a variable, which is shown as
+// a string if the variable is known to be a string, or as a number
+// otherwise.
+func (d *disassembler) literal(id uint8, value string, ins *interpreter.Instruction) string {
+	if d.types[id] == interpreter.Y2KString {
+		return strconv.Quote(decodeString(value, ins))
+	}
+
+	if number, ok := new(big.Int).SetString(value, 10); ok {
+		return number.String()
+	}
+
+	return "0"
+}
+
+// decodeString converts N-sized chunks of digits to characters, using the
+// character set of the instruction.
+func decodeString(value string, ins *interpreter.Instruction) string {
+	return utils.StrArrToText(utils.SplitStrByN(value, ins.Digits), ins.Unicode)
+}
+
+// decodeFloat places the decimal point in the digits of a float, using the
+// first digit as the decimal position.
+func decodeFloat(value string) string {
+	if len(value) == 0 || utils.StrToInt(value[:1])+1 > len(value) {
+		return value
+	}
+
+	decimalIndex := utils.StrToInt(value[:1])
+	return value[1:decimalIndex+1] + "." + value[decimalIndex+1:]
+}
+
+// varName formats a variable ID as "v1".
+func varName(value string) string {
+	id, ok := interpreter.ParseVarID(value)
+	if !ok {
+		return "v?" + value
+	}
+
+	return fmt.Sprintf("v%d", id)
+}
+
+// groupDigits separates the chunks of a multi-digit value with spaces.
+func groupDigits(value string, digits int) string {
+	if digits == 1 {
+		return value
+	}
+
+	return strings.Join(utils.SplitStrByN(value, digits), " ")
+}
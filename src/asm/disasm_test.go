@@ -0,0 +1,217 @@
+package asm_test
+
+import (
+	"bytes"
+	"github.com/benbusby/y2k/src/asm"
+	"github.com/benbusby/y2k/src/interpreter"
+	"github.com/benbusby/y2k/src/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// disasmCase is a program to disassemble, written either as a raw file or
+// as a directory of timestamp files (exported from the raw file), along with
+// the expected output.
+type disasmCase struct {
+	name   string
+	raw    string
+	digits int
+	export bool
+	output string
+	run    string
+}
+
+var disasmCases = []disasmCase{
+	{
+		name: "raw file",
+		raw: `# Print "b" for odd numbers and "a" for even numbers, up to 4
+8 1 2 1 0
+6 1 2 1 1 4
+  7 1 1 0 1 1
+  6 1 4 0 1 2
+    9 1 1 1
+  2001
+    9 1 1 2
+  2000
+1999
+5 0 2
+09 01 01 04
+9
+`,
+		digits: 1,
+		output: `8121 0        # CREATE v1 int size=1 "0"  [offset 0, line 2, column 1]
+61211 4       # WHILE v1 < 4              [offset 5, line 3, column 1]
+    71101 1   # MODIFY v1 += 1            [offset 11, line 4, column 3]
+    61401 2   # IF v1 % 2 == 0            [offset 17, line 5, column 3]
+        911 1 # PRINT "a"                 [offset 23, line 6, column 5]
+    2001      # ELSE                      [offset 27, line 7, column 3]
+        911 2 # PRINT "b"                 [offset 31, line 8, column 5]
+    2000      # END                       [offset 35, line 9, column 3]
+1999          # END                       [offset 39, line 10, column 1]
+502           # META digits=2             [offset 43, line 11, column 1]
+09 01 01 04   # PRINT "d"                 [offset 46, line 12, column 1]
+9             # (unused)                  [offset 54, line 13, column 1]
+`,
+		run: "b\na\nb\na\nd\n",
+	},
+	{
+		name: "raw file with 2 digits",
+		raw: `03 05 01
+    06 01 03 00 01 10
+        09 01 03 02 09 07
+    2000
+    03 07
+2000
+08 01 02 01 50
+03 06 01
+`,
+		digits: 2,
+		output: `# Run with -d 2
+03 05 01                  # DEFINE sub 1              [offset 0, line 1, column 1]
+    06 01 03 00 01 10     # IF v1 > 1                 [offset 6, line 2, column 5]
+        09 01 03 02 09 07 # PRINT "big"               [offset 18, line 3, column 9]
+    2000                  # END                       [offset 30, line 4, column 5]
+    03 07                 # RETURN                    [offset 34, line 5, column 5]
+2000                      # END                       [offset 38, line 6, column 1]
+08 01 02 01 50            # CREATE v1 int size=1 "5"  [offset 42, line 7, column 1]
+03 06 01                  # CALL sub 1                [offset 52, line 8, column 1]
+`,
+		run: "big\n",
+	},
+	{
+		name: "timestamp files",
+		raw: `8 1 2 1 0
+6 1 2 1 1 3
+  7 1 1 0 1 1
+  9 2 1 1
+1999
+`,
+		digits: 1,
+		export: true,
+		output: `8121 0      # CREATE v1 int size=1 "0"  [offset 0, 0.y2k digit 1]
+61211 3     # WHILE v1 < 3              [offset 5, 0.y2k digit 6]
+    71101 1 # MODIFY v1 += 1            [offset 11, 0.y2k digit 12]
+    921 1   # PRINT var v1              [offset 17, 0.y2k digit 18]
+1999        # END                       [offset 21, 1.y2k digit 5]
+0           # NOP                       [offset 25, 1.y2k digit 9]
+0           # NOP                       [offset 26, 1.y2k digit 10]
+0           # NOP                       [offset 27, 1.y2k digit 11]
+0           # NOP                       [offset 28, 1.y2k digit 12]
+0           # NOP                       [offset 29, 1.y2k digit 13]
+0           # NOP                       [offset 30, 1.y2k digit 14]
+0           # NOP                       [offset 31, 1.y2k digit 15]
+0           # NOP                       [offset 32, 1.y2k digit 16]
+0           # NOP                       [offset 33, 1.y2k digit 17]
+0           # NOP                       [offset 34, 1.y2k digit 18]
+`,
+		run: "1\n2\n3\n",
+	},
+}
+
+// load writes the program of a test case to a temporary directory, and
+// reads it back in the same way as the y2k command.
+func (tc disasmCase) load(t *testing.T) (string, *utils.SourceMap) {
+	t.Helper()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prog.y2k")
+	if err := os.WriteFile(path, []byte(tc.raw), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if tc.export {
+		timestamp, _, err := utils.GetTimestamps(path, tc.digits)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		path = filepath.Join(dir, "out")
+		if err := utils.ExportRawToTimestampFiles(timestamp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	timestamp, source, err := utils.GetTimestamps(path, tc.digits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return timestamp, source
+}
+
+// run runs a timestamp, and returns what it printed.
+func run(t *testing.T, timestamp string, digits int) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	y2k := interpreter.New(digits, false, strings.NewReader(""), &out)
+	if err := y2k.Parse(timestamp); err != nil {
+		t.Fatalf("can't run program: %v", err)
+	}
+
+	return out.String()
+}
+
+// disassemble disassembles the program of a test case, and checks that
+// nothing was run while doing so.
+func (tc disasmCase) disassemble(t *testing.T) string {
+	t.Helper()
+
+	timestamp, source := tc.load(t)
+
+	var out bytes.Buffer
+	y2k := interpreter.New(tc.digits, false, strings.NewReader(""), &out)
+	y2k.Source = source
+
+	var output bytes.Buffer
+	if err := asm.Disassemble(&output, y2k, timestamp); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Len() != 0 {
+		t.Errorf("program was run while disassembling: %q", out.String())
+	}
+
+	return output.String()
+}
+
+func TestDisassemble(t *testing.T) {
+	for _, tc := range disasmCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if output := tc.disassemble(t); output != tc.output {
+				t.Errorf("output:\n%s\nwant:\n%s", output, tc.output)
+			}
+		})
+	}
+}
+
+// TestDisassembleRoundTrip checks that the output of the disassembler is a
+// raw file with the same digits as the original program.
+func TestDisassembleRoundTrip(t *testing.T) {
+	for _, tc := range disasmCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "disasm.y2k")
+			if err := os.WriteFile(path, []byte(tc.disassemble(t)), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			want, _ := tc.load(t)
+			got, _, err := utils.GetTimestamps(path, tc.digits)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != want {
+				t.Errorf("timestamp = %s, want %s", got, want)
+			}
+
+			if output := run(t, got, tc.digits); output != tc.run {
+				t.Errorf("program printed %q, want %q", output, tc.run)
+			}
+		})
+	}
+}
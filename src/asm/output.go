@@ -0,0 +1,56 @@
+package asm
+
+import (
+	"fmt"
+	"io"
+)
+
+// indent is the indentation added for each block that a line is inside of.
+const indent = "    "
+
+// maxDigitsWidth limits how far comments are aligned, so that a single long
+// string doesn't push all other comments to the right.
+const maxDigitsWidth = 32
+
+// outputLine is a single line of a commented raw Y2K file, made up of the
+// digits of an instruction, a comment describing the instruction, and where
+// the instruction came from.
+type outputLine struct {
+	digits  string
+	comment string
+	where   string
+}
+
+// writeLines writes lines as a raw Y2K file, aligning the comments of each
+// line. If header isn't empty, it's written as a comment before the lines.
+func writeLines(w io.Writer, header string, lines []outputLine) error {
+	width := 0
+	commentWidth := 0
+	for _, line := range lines {
+		if len(line.digits) > width && len(line.digits) <= maxDigitsWidth {
+			width = len(line.digits)
+		}
+
+		if len(line.comment) > commentWidth {
+			commentWidth = len(line.comment)
+		}
+	}
+
+	if len(header) > 0 {
+		if _, err := fmt.Fprintf(w, "# %s\n", header); err != nil {
+			return err
+		}
+	}
+
+	for _, line := range lines {
+		_, err := fmt.Fprintf(w, "%-*s # %-*s  [%s]\n",
+			width, line.digits,
+			commentWidth, line.comment,
+			line.where)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
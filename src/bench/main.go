@@ -0,0 +1,60 @@
+// Command bench times how long the example programs take to run with a y2k
+// executable, by running each one many times with its output discarded.
+// Since it only runs the executable, it can time executables built from any
+// version of the interpreter. It's used by bench.sh to compare the current
+// interpreter against an older one.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const skipBench = "SKIP_TEST"
+
+// input is given to programs that read from stdin.
+const input = "21\n"
+
+func main() {
+	bin := flag.String("bin", "./y2k", "Set the y2k executable to time")
+	runs := flag.Int("n", 100, "Set # of times to run each program")
+	arg := flag.String("arg", "15", "Set the command line argument passed to each program")
+	flag.Parse()
+
+	files := flag.Args()
+	if len(files) == 0 {
+		files, _ = filepath.Glob("examples/*.y2k")
+	}
+
+	for _, file := range files {
+		// Programs that never finish (like "count-up-forever.y2k") can't
+		// be timed.
+		contents, err := os.ReadFile(file)
+		if err != nil || strings.Contains(string(contents), skipBench) {
+			continue
+		}
+
+		start := time.Now()
+		for i := 0; i < *runs; i++ {
+			var stderr bytes.Buffer
+			cmd := exec.Command(*bin, file, *arg)
+			cmd.Stdin = strings.NewReader(input)
+			cmd.Stdout = io.Discard
+			cmd.Stderr = &stderr
+			if err := cmd.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s: %s\n%s", file, err, stderr.String())
+				os.Exit(1)
+			}
+		}
+
+		elapsed := time.Since(start) / time.Duration(*runs)
+		fmt.Printf("%-28s %10d us/op\n", filepath.Base(file), elapsed.Microseconds())
+	}
+}
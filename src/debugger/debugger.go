@@ -0,0 +1,465 @@
+// Package debugger provides an interactive, terminal based debugger for Y2K
+// programs, which is used by the "y2k debug" command.
+package debugger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/benbusby/y2k/src/interpreter"
+	"github.com/benbusby/y2k/src/utils"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const prompt = "(y2k) "
+
+const helpMsg = `Commands:
+  s, step [N]        Run the next N instructions (default 1)
+  n, next            Run the next instruction, including the whole block if
+                     it starts a condition
+  c, continue        Run until a breakpoint, watchpoint or the end of the program
+  b, break <line>    Stop before running an instruction on a raw file line
+  b, break <file>:<line>
+  b, break @<offset> Stop before running the instruction at a digit offset
+  b, break           List breakpoints
+  d, delete [N]      Delete breakpoint N, or all breakpoints
+  w, watch <id>      Stop when the variable with the given ID changes
+  unwatch <id>       Remove a watchpoint
+  v, vars            Print all variables with their types and sizes
+  l, list            Print the instructions around the next instruction
+  h, help            Print this message
+  q, quit            Stop debugging
+
+Pressing enter without a command repeats the last command.`
+
+// Debugger reads commands from the terminal and uses them to control a
+// Session.
+type Debugger struct {
+	session *interpreter.Session
+	program *interpreter.Program
+	source  *utils.SourceMap
+	in      *bufio.Reader
+	out     io.Writer
+
+	breakpoints []breakpoint
+	watches     map[uint8]string
+	last        string
+}
+
+// breakpoint stops the program before an instruction at a digit offset, or
+// on a line of a raw file (if offset is -1).
+type breakpoint struct {
+	offset int
+	file   string
+	line   int
+}
+
+// New creates a Debugger for a program. Commands are read from in, and the
+// debugger's messages are written to out. The source map is optional, but
+// is needed for breakpoints on raw file lines.
+func New(
+	session *interpreter.Session,
+	program *interpreter.Program,
+	source *utils.SourceMap,
+	in *bufio.Reader,
+	out io.Writer,
+) *Debugger {
+	return &Debugger{
+		session: session,
+		program: program,
+		source:  source,
+		in:      in,
+		out:     out,
+		watches: map[uint8]string{},
+	}
+}
+
+// Run reads and runs debugger commands until the user quits or the input
+// ends. The error that stopped the program (if any) is returned.
+func (d *Debugger) Run() error {
+	d.printf("Type \"help\" for a list of commands.\n")
+	d.where()
+
+	for {
+		d.printf(prompt)
+		line, err := d.in.ReadString('\n')
+		if len(line) == 0 && err != nil {
+			d.printf("\n")
+			return d.session.Err()
+		}
+
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			line = d.last
+		}
+		d.last = line
+
+		if !d.command(strings.Fields(line)) {
+			return d.session.Err()
+		}
+	}
+}
+
+// command runs a single debugger command, returning false if the debugger
+// should stop.
+func (d *Debugger) command(args []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+
+	switch args[0] {
+	case "s", "step":
+		count := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				d.printf("Invalid count: %s\n", args[1])
+				return true
+			}
+			count = n
+		}
+
+		steps := 0
+		d.resume(func() bool {
+			steps++
+			return steps >= count
+		})
+	case "n", "next":
+		depth := d.session.Depth()
+		d.resume(func() bool {
+			return d.session.Depth() <= depth
+		})
+	case "c", "continue":
+		d.resume(func() bool {
+			return false
+		})
+	case "b", "break":
+		d.addBreakpoint(args[1:])
+	case "d", "delete":
+		d.deleteBreakpoint(args[1:])
+	case "w", "watch":
+		d.watch(args[1:], true)
+	case "unwatch":
+		d.watch(args[1:], false)
+	case "v", "vars":
+		d.vars()
+	case "l", "list":
+		d.list()
+	case "h", "help":
+		d.printf("%s\n", helpMsg)
+	case "q", "quit":
+		return false
+	default:
+		d.printf("Unknown command %q (type \"help\" for a list of commands)\n", args[0])
+	}
+
+	return true
+}
+
+// resume runs instructions until stop returns true, a breakpoint or
+// watchpoint is hit, or the program ends.
+func (d *Debugger) resume(stop func() bool) {
+	if d.session.Done() {
+		d.finished()
+		return
+	}
+
+	for first := true; !d.session.Done(); first = false {
+		// Breakpoints are skipped for the first instruction, so that
+		// running again after stopping at a breakpoint doesn't stop at
+		// the same breakpoint.
+		if !first {
+			if n, ok := d.breakpointAt(d.session.Next()); ok {
+				d.printf("Breakpoint %d\n", n)
+				break
+			}
+		}
+
+		if err := d.session.Step(); err != nil {
+			break
+		}
+
+		if d.checkWatches() || stop() {
+			break
+		}
+	}
+
+	d.where()
+}
+
+// where prints the next instruction, or why the program has stopped.
+func (d *Debugger) where() {
+	if d.session.Done() {
+		d.finished()
+		return
+	}
+
+	d.printInstruction(d.session.PC(), "=>")
+}
+
+// finished prints how the program ended.
+func (d *Debugger) finished() {
+	var exitErr *interpreter.ExitError
+	if err := d.session.Err(); errors.As(err, &exitErr) {
+		d.printf("Program exited with status %d\n", exitErr.Code)
+		return
+	} else if err != nil {
+		d.printf("Error: %s\n", err)
+		return
+	}
+
+	d.printf("Program finished\n")
+}
+
+// printInstruction prints the instruction at an index of the program,
+// with its digit offset and source position.
+func (d *Debugger) printInstruction(idx int, marker string) {
+	ins := &d.program.Instructions[idx]
+	msg := fmt.Sprintf("%2s %4d  offset %-5d %s", marker, idx, ins.Offset, ins)
+	if pos := d.source.Describe(ins.Offset); len(pos) > 0 {
+		msg += fmt.Sprintf("  (%s)", pos)
+	}
+
+	d.printf("%s\n", msg)
+}
+
+// list prints the instructions around the next instruction.
+func (d *Debugger) list() {
+	pc := d.session.PC()
+	start := pc - 5
+	if start < 0 {
+		start = 0
+	}
+
+	for i := start; i < len(d.program.Instructions) && i <= pc+5; i++ {
+		marker := ""
+		if i == pc && !d.session.Done() {
+			marker = "=>"
+		}
+		d.printInstruction(i, marker)
+	}
+}
+
+// vars prints all variables, along with their types and sizes.
+func (d *Debugger) vars() {
+	vars := d.session.Vars()
+	if len(vars) == 0 {
+		d.printf("No variables\n")
+		return
+	}
+
+	for _, variable := range vars {
+		d.printf("%s\n", describeVar(variable))
+	}
+}
+
+// describeVar formats a variable as "v1  int     size=4  1999".
+func describeVar(variable *interpreter.Y2KVar) string {
+	value := variable.GetValue()
+	if variable.Type == interpreter.Y2KString {
+		value = strconv.Quote(value)
+	}
+
+	return fmt.Sprintf("v%-3d %-7s size=%-3d %s",
+		variable.ID,
+		variable.Type,
+		variable.Len(),
+		value)
+}
+
+// addBreakpoint adds a breakpoint from a "break" command, or lists the
+// current breakpoints if no location is given.
+func (d *Debugger) addBreakpoint(args []string) {
+	if len(args) == 0 {
+		if len(d.breakpoints) == 0 {
+			d.printf("No breakpoints\n")
+		}
+
+		for i, bp := range d.breakpoints {
+			d.printf("%d: %s\n", i+1, bp)
+		}
+		return
+	}
+
+	bp, err := parseBreakpoint(args[0])
+	if err != nil {
+		d.printf("%s\n", err)
+		return
+	}
+
+	d.breakpoints = append(d.breakpoints, bp)
+	d.printf("Breakpoint %d at %s\n", len(d.breakpoints), bp)
+}
+
+// parseBreakpoint reads a breakpoint location, which is either a line
+// number, a file and line number ("file.y2k:12"), or a digit offset ("@40").
+func parseBreakpoint(location string) (breakpoint, error) {
+	if strings.HasPrefix(location, "@") {
+		offset, err := strconv.Atoi(location[1:])
+		if err != nil || offset < 0 {
+			return breakpoint{}, fmt.Errorf("Invalid offset: %s", location[1:])
+		}
+
+		return breakpoint{offset: offset}, nil
+	}
+
+	bp := breakpoint{offset: -1}
+	lineStr := location
+	if idx := strings.LastIndex(location, ":"); idx >= 0 {
+		bp.file = location[:idx]
+		lineStr = location[idx+1:]
+	}
+
+	line, err := strconv.Atoi(lineStr)
+	if err != nil || line < 1 {
+		return breakpoint{}, fmt.Errorf("Invalid line: %s", lineStr)
+	}
+	bp.line = line
+
+	return bp, nil
+}
+
+func (bp breakpoint) String() string {
+	if bp.offset >= 0 {
+		return fmt.Sprintf("offset %d", bp.offset)
+	}
+
+	if len(bp.file) > 0 {
+		return fmt.Sprintf("%s line %d", bp.file, bp.line)
+	}
+
+	return fmt.Sprintf("line %d", bp.line)
+}
+
+// deleteBreakpoint removes a breakpoint by number, or all breakpoints if no
+// number is given.
+func (d *Debugger) deleteBreakpoint(args []string) {
+	if len(args) == 0 {
+		d.breakpoints = nil
+		d.printf("Deleted all breakpoints\n")
+		return
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 || n > len(d.breakpoints) {
+		d.printf("No breakpoint %s\n", args[0])
+		return
+	}
+
+	d.breakpoints = append(d.breakpoints[:n-1], d.breakpoints[n:]...)
+	d.printf("Deleted breakpoint %d\n", n)
+}
+
+// breakpointAt returns the number of the first breakpoint that matches an
+// instruction.
+func (d *Debugger) breakpointAt(ins *interpreter.Instruction) (int, bool) {
+	for i, bp := range d.breakpoints {
+		if d.matches(bp, ins) {
+			return i + 1, true
+		}
+	}
+
+	return 0, false
+}
+
+// matches checks if an instruction contains the offset of a breakpoint, or
+// was read from the breakpoint's line.
+func (d *Debugger) matches(bp breakpoint, ins *interpreter.Instruction) bool {
+	last := ins.Offset
+	if ins.Size > 1 {
+		last += ins.Size - 1
+	}
+
+	if bp.offset >= 0 {
+		return ins.Offset <= bp.offset && bp.offset <= last
+	}
+
+	start, ok := d.source.Lookup(ins.Offset)
+	if !ok || start.Line == 0 {
+		return false
+	}
+
+	if len(bp.file) > 0 &&
+		bp.file != start.File &&
+		bp.file != filepath.Base(start.File) {
+		return false
+	}
+
+	// Instructions can continue onto the following lines of the same file
+	end, ok := d.source.Lookup(last)
+	if !ok || end.File != start.File {
+		end = start
+	}
+
+	return start.Line <= bp.line && bp.line <= end.Line
+}
+
+// watch adds or removes a watchpoint on a variable ID.
+func (d *Debugger) watch(args []string, add bool) {
+	if len(args) == 0 {
+		if len(d.watches) == 0 {
+			d.printf("No watchpoints\n")
+		}
+
+		ids := make([]int, 0, len(d.watches))
+		for id := range d.watches {
+			ids = append(ids, int(id))
+		}
+		sort.Ints(ids)
+
+		for _, id := range ids {
+			d.printf("v%d\n", id)
+		}
+		return
+	}
+
+	id, ok := interpreter.ParseVarID(strings.TrimPrefix(args[0], "v"))
+	if !ok {
+		d.printf("Invalid variable ID: %s\n", args[0])
+		return
+	}
+
+	if !add {
+		delete(d.watches, id)
+		d.printf("Removed watchpoint on v%d\n", id)
+		return
+	}
+
+	d.watches[id] = d.snapshot(id)
+	d.printf("Watching v%d\n", id)
+}
+
+// snapshot describes the current state of a variable, so that changes to
+// the variable can be detected.
+func (d *Debugger) snapshot(id uint8) string {
+	variable, ok := d.session.Var(id)
+	if !ok {
+		return "(not created)"
+	}
+
+	return describeVar(variable)
+}
+
+// checkWatches prints any watched variables that have changed, returning
+// true if there were any.
+func (d *Debugger) checkWatches() bool {
+	changed := false
+	for id, old := range d.watches {
+		current := d.snapshot(id)
+		if current != old {
+			d.printf("Watchpoint v%d\n  old: %s\n  new: %s\n", id, old, current)
+			d.watches[id] = current
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+func (d *Debugger) printf(template string, args ...interface{}) {
+	_, _ = fmt.Fprintf(d.out, template, args...)
+}
@@ -0,0 +1,251 @@
+package debugger_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"github.com/benbusby/y2k/src/debugger"
+	"github.com/benbusby/y2k/src/interpreter"
+	"github.com/benbusby/y2k/src/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// eof is printed when the debugger runs out of commands to read.
+const eof = "(y2k) \n"
+
+// countProgram counts to 3 in a loop, printing each number, then prints
+// "d".
+const countProgram = `# Count to 3, then print "d"
+8 1 2 1 0
+6 1 2 1 1 3
+  7 1 1 0 1 1
+  9 2 1 1
+1999
+9 1 1 4
+`
+
+// debug runs the debugger on a raw program with a script of commands, and
+// returns everything that was printed by both the debugger and the
+// program. The path of the raw file is shown as "prog.y2k".
+func debug(t *testing.T, raw string, script string) (string, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "prog.y2k")
+	if err := os.WriteFile(path, []byte(raw), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp, source, err := utils.GetTimestamps(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	y2k := interpreter.New(1, false, strings.NewReader(""), &out)
+	y2k.Source = source
+
+	program, err := y2k.Compile(timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := y2k.NewSession(program)
+	in := bufio.NewReader(strings.NewReader(script))
+	err = debugger.New(session, program, source, in, &out).Run()
+
+	return strings.ReplaceAll(out.String(), path, "prog.y2k"), err
+}
+
+func TestDebugger(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		script string
+		want   string
+	}{
+		{
+			name:   "step, next and continue",
+			raw:    countProgram,
+			script: "step\nstep 2\nnext\n\ncontinue\nstep\n",
+			want: `Type "help" for a list of commands.
+=>    0  offset 0     CREATE ID=1 Type=2 Size=1 value=0  (prog.y2k line 2, column 1)
+(y2k) =>    1  offset 5     CONDITION VarID=1 CompFn=2 Flags=1 CompValSize=1 value=3  (prog.y2k line 3, column 1)
+(y2k) =>    3  offset 17    PRINT Type=2 Size=1 value=1  (prog.y2k line 5, column 3)
+(y2k) 1
+=>    4  offset 21    END 1999  (prog.y2k line 6, column 1)
+(y2k) =>    2  offset 11    MODIFY VarID=1 ModFn=1 ArgIsVar=0 ModSize=1 value=1  (prog.y2k line 4, column 3)
+(y2k) 2
+3
+d
+Program finished
+(y2k) Program finished
+` + eof,
+		},
+		{
+			name:   "next runs a whole block",
+			raw:    countProgram,
+			script: "step\nnext\n",
+			want: `Type "help" for a list of commands.
+=>    0  offset 0     CREATE ID=1 Type=2 Size=1 value=0  (prog.y2k line 2, column 1)
+(y2k) =>    1  offset 5     CONDITION VarID=1 CompFn=2 Flags=1 CompValSize=1 value=3  (prog.y2k line 3, column 1)
+(y2k) 1
+2
+3
+=>    5  offset 25    PRINT Type=1 Size=1 value=4  (prog.y2k line 7, column 1)
+` + eof,
+		},
+		{
+			name:   "breakpoint on a line",
+			raw:    countProgram,
+			script: "break 5\ncontinue\ncontinue\nbreak\ndelete 1\nbreak\ncontinue\n",
+			want: `Type "help" for a list of commands.
+=>    0  offset 0     CREATE ID=1 Type=2 Size=1 value=0  (prog.y2k line 2, column 1)
+(y2k) Breakpoint 1 at line 5
+(y2k) Breakpoint 1
+=>    3  offset 17    PRINT Type=2 Size=1 value=1  (prog.y2k line 5, column 3)
+(y2k) 1
+Breakpoint 1
+=>    3  offset 17    PRINT Type=2 Size=1 value=1  (prog.y2k line 5, column 3)
+(y2k) 1: line 5
+(y2k) Deleted breakpoint 1
+(y2k) No breakpoints
+(y2k) 2
+3
+d
+Program finished
+` + eof,
+		},
+		{
+			name:   "breakpoint on a file and line",
+			raw:    countProgram,
+			script: "break other.y2k:5\nbreak prog.y2k:7\ncontinue\n",
+			want: `Type "help" for a list of commands.
+=>    0  offset 0     CREATE ID=1 Type=2 Size=1 value=0  (prog.y2k line 2, column 1)
+(y2k) Breakpoint 1 at other.y2k line 5
+(y2k) Breakpoint 2 at prog.y2k line 7
+(y2k) 1
+2
+3
+Breakpoint 2
+=>    5  offset 25    PRINT Type=1 Size=1 value=4  (prog.y2k line 7, column 1)
+` + eof,
+		},
+		{
+			name:   "breakpoint inside of an instruction's digits",
+			raw:    countProgram,
+			script: "break @27\ncontinue\n",
+			want: `Type "help" for a list of commands.
+=>    0  offset 0     CREATE ID=1 Type=2 Size=1 value=0  (prog.y2k line 2, column 1)
+(y2k) Breakpoint 1 at offset 27
+(y2k) 1
+2
+3
+Breakpoint 1
+=>    5  offset 25    PRINT Type=1 Size=1 value=4  (prog.y2k line 7, column 1)
+` + eof,
+		},
+		{
+			name:   "watchpoints",
+			raw:    countProgram,
+			script: "watch v1\ncontinue\ncontinue\nunwatch 1\nwatch\ncontinue\n",
+			want: `Type "help" for a list of commands.
+=>    0  offset 0     CREATE ID=1 Type=2 Size=1 value=0  (prog.y2k line 2, column 1)
+(y2k) Watching v1
+(y2k) Watchpoint v1
+  old: (not created)
+  new: v1   int     size=1   0
+=>    1  offset 5     CONDITION VarID=1 CompFn=2 Flags=1 CompValSize=1 value=3  (prog.y2k line 3, column 1)
+(y2k) Watchpoint v1
+  old: v1   int     size=1   0
+  new: v1   int     size=1   1
+=>    3  offset 17    PRINT Type=2 Size=1 value=1  (prog.y2k line 5, column 3)
+(y2k) Removed watchpoint on v1
+(y2k) No watchpoints
+(y2k) 1
+2
+3
+d
+Program finished
+` + eof,
+		},
+		{
+			name:   "vars",
+			raw:    "8 1 2 1 5\n8 2 1 2 1 2\n7 2 3 0 1 3\n9 1 1 3\n",
+			script: "vars\nstep 3\nvars\nquit\n",
+			want: `Type "help" for a list of commands.
+=>    0  offset 0     CREATE ID=1 Type=2 Size=1 value=5  (prog.y2k line 1, column 1)
+(y2k) No variables
+(y2k) =>    3  offset 17    PRINT Type=1 Size=1 value=3  (prog.y2k line 4, column 1)
+(y2k) v1   int     size=1   5
+v2   string  size=6   "ababab"
+(y2k) `,
+		},
+		{
+			name:   "invalid commands",
+			raw:    countProgram,
+			script: "break x\nbreak @y\nbogus\nstep 0\ndelete 3\nwatch 999\n",
+			want: `Type "help" for a list of commands.
+=>    0  offset 0     CREATE ID=1 Type=2 Size=1 value=0  (prog.y2k line 2, column 1)
+(y2k) Invalid line: x
+(y2k) Invalid offset: y
+(y2k) Unknown command "bogus" (type "help" for a list of commands)
+(y2k) Invalid count: 0
+(y2k) No breakpoint 3
+(y2k) Invalid variable ID: 999
+` + eof,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := debug(t, tc.raw, tc.script)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if got != tc.want {
+				t.Errorf("output:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDebuggerExit(t *testing.T) {
+	got, err := debug(t, "9 1 1 1\n3 0 0 1 3\n9 1 1 2\n", "continue\nstep\n")
+
+	var exitErr *interpreter.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Errorf("error = %v, want exit status 3", err)
+	}
+
+	want := `Type "help" for a list of commands.
+=>    0  offset 0     PRINT Type=1 Size=1 value=1  (prog.y2k line 1, column 1)
+(y2k) a
+Program exited with status 3
+(y2k) Program exited with status 3
+` + eof
+	if got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDebuggerError(t *testing.T) {
+	got, err := debug(t, "8 1 2 1 7\n7 1 4 0 1 0\n", "step\nstep\nstep\n")
+	if !errors.Is(err, interpreter.ErrInvalidValue) {
+		t.Errorf("error = %v, want %v", err, interpreter.ErrInvalidValue)
+	}
+
+	want := `Type "help" for a list of commands.
+=>    0  offset 0     CREATE ID=1 Type=2 Size=1 value=7  (prog.y2k line 1, column 1)
+(y2k) =>    1  offset 5     MODIFY VarID=1 ModFn=4 ArgIsVar=0 ModSize=1 value=0  (prog.y2k line 2, column 1)
+(y2k) Error: offset 10 (prog.y2k line 2, column 11): MODIFY.value: invalid value: division by zero
+(y2k) Error: offset 10 (prog.y2k line 2, column 11): MODIFY.value: invalid value: division by zero
+` + eof
+	if got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+package interpreter_test
+
+import (
+	"github.com/benbusby/y2k/src/interpreter"
+	"github.com/benbusby/y2k/src/utils"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// BenchmarkExamples times each of the example programs, run in-process with
+// their output discarded.
+func BenchmarkExamples(b *testing.B) {
+	files, err := filepath.Glob("../../examples/*.y2k")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, file := range files {
+		// Programs that never finish (like "count-up-forever.y2k") can't
+		// be timed.
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			b.Fatal(err)
+		} else if strings.Contains(string(contents), "SKIP_TEST") {
+			continue
+		}
+
+		timestamp, _, err := utils.GetTimestamps(file, 1)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(filepath.Base(file), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				y2k := interpreter.New(1, false, strings.NewReader("21\n"), io.Discard)
+				y2k.FromCLIArg("15")
+				if err := y2k.Parse(timestamp); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkLoop times a loop that counts to 100000, which mostly measures
+// how quickly instructions are run once they've been decoded.
+func BenchmarkLoop(b *testing.B) {
+	y2k := interpreter.New(1, false, strings.NewReader(""), io.Discard)
+	program, err := y2k.Compile("8121061216100000711011")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for i := 0; i < b.N; i++ {
+		if err := y2k.Run(program); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
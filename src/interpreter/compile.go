@@ -0,0 +1,574 @@
+package interpreter
+
+import (
+	"fmt"
+	"github.com/benbusby/y2k/src/utils"
+	"strings"
+)
+
+// Op is the kind of operation that an Instruction performs when it's run.
+type Op uint8
+
+const (
+	// OpCommand runs a PRINT, CREATE, MODIFY or extended command. META
+	// commands are also compiled to OpCommand, but are applied while
+	// compiling and have no effect when run.
+	OpCommand Op = iota
+
+	// OpCondition starts a block if its condition is true, otherwise it
+	// jumps past the block's OpEnd instruction (the index of which is
+	// stored in Jump). If the block has an else branch, Jump is the index
+	// of the block's OpElse instruction instead, and the block is started
+	// at the else branch.
+	OpCondition
+
+	// OpClause adds a comparison to the OpCondition before it, which reads
+	// it when the condition is checked. OpClause instructions always
+	// directly follow an OpCondition or another OpClause.
+	OpClause
+
+	// OpEnd marks the end of a block. Loops jump back to the start of the
+	// block's body (after their OpCondition, stored in Jump, and any
+	// OpClause instructions) if the condition is still true.
+	OpEnd
+
+	// OpElse marks the start of the else branch of an if block. It's only
+	// run at the end of the if branch, and jumps to the block's OpEnd
+	// instruction (stored in Jump).
+	OpElse
+
+	// OpContinue skips to the end of the innermost loop, stored in Jump as
+	// the index of the loop's OpCondition, where the loop's condition is
+	// checked again. If Jump is -1, the program ends.
+	OpContinue
+
+	// OpBreak exits the innermost loop, stored in Jump as the index of the
+	// loop's OpCondition, and continues after the loop's OpEnd.
+	OpBreak
+
+	// OpDefine starts the body of a subroutine, which is skipped when the
+	// OpDefine is run by jumping past the body's OpEnd (stored in Jump).
+	OpDefine
+
+	// OpCall runs the body of a subroutine, stored in Jump as the index of
+	// the subroutine's OpDefine. If Jump is -1, the subroutine was never
+	// defined.
+	OpCall
+
+	// OpReturn exits the subroutine that is running, and continues after
+	// the OpCall that started it.
+	OpReturn
+
+	// OpSkip is a command that has no effect (any unknown command).
+	OpSkip
+
+	// OpError is a command that couldn't be decoded. Running it returns
+	// Err, so that errors are only reported for code that actually runs.
+	OpError
+)
+
+// Instruction is a single decoded command from a timestamp.
+type Instruction struct {
+	Op      Op
+	Command Y2KCommand
+
+	// Offset is the position of the first digit of the instruction in the
+	// timestamp, and Size is the number of digits it was decoded from.
+	Offset int
+	Size   int
+
+	// Digits, Debug, Unicode and Stderr are the interpreter values the
+	// instruction was compiled with, which can be changed by META commands.
+	// Unicode is true if character codes are read as Unicode code points,
+	// rather than as indexes of utils.Printable, and Stderr is true if PRINT
+	// commands write to stderr instead of the regular output.
+	Digits  int
+	Debug   bool
+	Unicode bool
+	Stderr  bool
+
+	// Fields holds the value of each of the command's fields (in the order
+	// listed by the command's Schema), and Value holds the raw digits that
+	// followed them.
+	Fields []int
+	Value  string
+
+	Jump int
+	Err  error
+
+	valueOffset int
+	exec        func(*machine, *Instruction) error
+	trace       []string
+}
+
+// Program is a timestamp that has been decoded into a list of instructions,
+// which can be run any number of times without decoding it again.
+type Program struct {
+	Instructions []Instruction
+}
+
+// compiler decodes a timestamp into a Program, tracking the blocks that
+// are open and the Digits, Debug, Unicode and Stderr values in use at each
+// point in the same way that the timestamp would be read when it's run.
+type compiler struct {
+	Y2K
+	timestamp string
+	pos       int
+	blocks    []openBlock
+	program   []Instruction
+	subs      map[int]int
+	unicode   bool
+	stderr    bool
+}
+
+// openBlock is the body of a condition or subroutine that is being
+// compiled.
+type openBlock struct {
+	header  int
+	elseAt  int
+	loop    bool
+	define  bool
+	term    string
+	digits  int
+	debug   bool
+	unicode bool
+	stderr  bool
+}
+
+// Compile decodes a full timestamp into a Program. Only problems with the
+// timestamp as a whole are returned as errors; commands that can't be
+// decoded become OpError instructions, and are reported if they're run.
+func (y2k Y2K) Compile(timestamp string) (*Program, error) {
+	if y2k.Digits < 1 {
+		return nil, y2k.errorAt(0, "Digits", ErrInvalidDigits)
+	}
+
+	for i, c := range timestamp {
+		if c < '0' || c > '9' {
+			return nil, y2k.errorAt(i, "", fmt.Errorf("%w: %q", ErrInvalidDigit, c))
+		}
+	}
+
+	c := &compiler{Y2K: y2k, timestamp: timestamp, subs: map[int]int{}}
+	c.compile()
+	c.link()
+
+	return &Program{Instructions: c.program}, nil
+}
+
+// compile decodes instructions until the end of the timestamp is reached.
+func (c *compiler) compile() {
+	for {
+		timestamp := c.timestamp[c.pos:]
+		if idx := c.endingBlock(timestamp); idx >= 0 {
+			c.closeBlock(idx == len(c.blocks)-1)
+			continue
+		}
+
+		if len(timestamp) < c.Digits {
+			// Finished decoding the whole timestamp, so any blocks that
+			// are still open end here
+			if len(c.blocks) == 0 {
+				return
+			}
+
+			c.closeBlock(false)
+			continue
+		}
+
+		if c.atElse(timestamp) {
+			c.elseBranch()
+			continue
+		}
+
+		ins := Instruction{
+			Offset:  c.pos,
+			Size:    c.Digits,
+			Digits:  c.Digits,
+			Debug:   c.Debug,
+			Unicode: c.unicode,
+			Stderr:  c.stderr,
+			Jump:    -1,
+		}
+
+		c.debugAt(&ins, c.pos, "Parse: [%s]%s",
+			timestamp[:c.Digits],
+			timestamp[c.Digits:],
+		)
+		ins.Command = Y2KCommand(utils.StrToInt(timestamp[:c.Digits]))
+
+		// Extended commands aren't in schemaMap, so they can only be
+		// read after an EXTEND command
+		var err error
+		schema, ok := schemaMap[ins.Command]
+		if ok && ins.Command == EXTEND {
+			schema, err = c.extend(&ins, schema)
+		}
+
+		if !ok {
+			ins.Op = OpSkip
+		} else if err == nil {
+			err = c.decode(&ins, schema)
+		}
+
+		if err != nil {
+			// Nothing after the error can be run, so the rest of the
+			// block doesn't need to be decoded.
+			ins.Op = OpError
+			ins.Err = withCommand(err, ins.Command)
+			ins.Size = c.skipBlock(timestamp)
+		}
+
+		c.pos += ins.Size
+		c.program = append(c.program, ins)
+	}
+}
+
+// link sets the Jump of each OpCall instruction to the OpDefine of the
+// subroutine it calls. This is done once the whole timestamp is decoded, so
+// that subroutines can be called before they're defined.
+func (c *compiler) link() {
+	for i := range c.program {
+		ins := &c.program[i]
+		if ins.Op != OpCall {
+			continue
+		}
+
+		if define, ok := c.subs[ins.Fields[subID]]; ok {
+			ins.Jump = define
+		}
+	}
+}
+
+// extend reads the ID of an extended command, which follows the EXTEND
+// command, and returns the extended command's schema.
+func (c *compiler) extend(ins *Instruction, schema *Schema) (*Schema, error) {
+	field := schema.Fields[extendID]
+	offset := c.pos + c.Digits
+	if len(c.timestamp)-offset < c.Digits {
+		return nil, c.errorAt(len(c.timestamp), field.Name, ErrUnexpectedEnd)
+	}
+
+	chunk := c.timestamp[offset : offset+c.Digits]
+	c.debugAt(ins, offset, "%s.%s: [%s]%s",
+		schema.Name,
+		field.Name,
+		chunk,
+		c.timestamp[offset+c.Digits:],
+	)
+
+	id := utils.StrToInt(chunk)
+	if err := field.check(id); err != nil {
+		return nil, c.errorAt(offset, field.Name, err)
+	}
+
+	ins.Command = extendedBase + Y2KCommand(id)
+	ins.Size += c.Digits
+
+	return extendedMap[id], nil
+}
+
+// decode reads the fields and value of a command into an instruction. If
+// the command is a condition, a new block is opened for its body.
+func (c *compiler) decode(ins *Instruction, schema *Schema) error {
+	ins.Op = schema.op
+	ins.exec = schema.exec
+	ins.Fields = make([]int, len(schema.Fields))
+
+	offset := c.pos + ins.Size
+	for i, field := range schema.Fields {
+		if len(c.timestamp)-offset < c.Digits {
+			return c.errorAt(len(c.timestamp), field.Name, ErrUnexpectedEnd)
+		}
+
+		chunk := c.timestamp[offset : offset+c.Digits]
+		c.debugAt(ins, offset, "%s.%s: [%s]%s",
+			schema.Name,
+			field.Name,
+			chunk,
+			c.timestamp[offset+c.Digits:],
+		)
+
+		ins.Fields[i] = utils.StrToInt(chunk)
+		if err := field.check(ins.Fields[i]); err != nil {
+			return c.errorAt(offset, field.Name, err)
+		}
+
+		offset += c.Digits
+	}
+
+	ins.valueOffset = offset
+
+	// At least one chunk of the value is always read, even if the command
+	// has a size of 0.
+	if schema.Chunks != nil {
+		chunks := schema.Chunks(ins.Fields, c.Digits)
+		for i := 0; i < chunks || i == 0; i++ {
+			if len(c.timestamp)-offset < c.Digits {
+				return c.errorAt(len(c.timestamp), "value", ErrUnexpectedEnd)
+			}
+
+			c.debugAt(ins, offset, "%s.value: [%s]%s",
+				schema.Name,
+				c.timestamp[offset:offset+c.Digits],
+				c.timestamp[offset+c.Digits:],
+			)
+
+			offset += c.Digits
+		}
+	}
+
+	ins.Value = c.timestamp[ins.valueOffset:offset]
+	ins.Size = offset - ins.Offset
+
+	switch ins.Op {
+	case OpCondition:
+		c.openBlock(ins.Fields[condFlags]&CondFlagLoop != 0)
+	case OpClause:
+		last := len(c.program) - 1
+		if last < 0 || (c.program[last].Op != OpCondition && c.program[last].Op != OpClause) {
+			return c.errorAt(ins.Offset, "", fmt.Errorf(
+				"%w: must directly follow a CONDITION or CLAUSE command",
+				ErrMisplaced))
+		}
+	case OpContinue, OpBreak:
+		// Loops outside of a subroutine can't be continued from within it
+		for i := len(c.blocks) - 1; i >= 0 && !c.blocks[i].define; i-- {
+			if c.blocks[i].loop {
+				ins.Jump = c.blocks[i].header
+				break
+			}
+		}
+
+		if ins.Op == OpBreak && ins.Jump < 0 {
+			return c.errorAt(ins.Offset, "", fmt.Errorf(
+				"%w: must be inside of a while loop",
+				ErrMisplaced))
+		}
+	case OpDefine:
+		if _, ok := c.subs[ins.Fields[subID]]; ok {
+			return c.errorAt(ins.Offset, "", fmt.Errorf(
+				"%w: subroutine %d is already defined",
+				ErrInvalidValue,
+				ins.Fields[subID]))
+		}
+
+		c.subs[ins.Fields[subID]] = len(c.program)
+		c.openBlock(false)
+		c.blocks[len(c.blocks)-1].define = true
+	case OpReturn:
+		inSub := false
+		for _, block := range c.blocks {
+			inSub = inSub || block.define
+		}
+
+		if !inSub {
+			return c.errorAt(ins.Offset, "", fmt.Errorf(
+				"%w: must be inside of a subroutine",
+				ErrMisplaced))
+		}
+	}
+
+	if ins.Command == META {
+		if ins.Fields[metaDigits] < 1 {
+			return c.errorAt(offset-c.Digits, "Digits", ErrInvalidDigits)
+		}
+
+		c.Debug = ins.Fields[metaFlags]&MetaFlagDebug != 0
+		c.unicode = ins.Fields[metaFlags]&MetaFlagUnicode != 0
+		c.stderr = ins.Fields[metaFlags]&MetaFlagStderr != 0
+		c.Digits = ins.Fields[metaDigits]
+	}
+
+	return nil
+}
+
+// openBlock starts a block for the body of a condition or subroutine. The
+// block ends at its terminator ("1999" for loops, "2000" otherwise), at the
+// terminator of a block it's nested in, or at the end of the timestamp.
+func (c *compiler) openBlock(loop bool) {
+	c.blocks = append(c.blocks, openBlock{
+		header:  len(c.program),
+		elseAt:  -1,
+		loop:    loop,
+		term:    utils.GetCondTerm(loop),
+		digits:  c.Digits,
+		debug:   c.Debug,
+		unicode: c.unicode,
+		stderr:  c.stderr,
+	})
+}
+
+// endingBlock returns the index of the innermost block whose terminator is
+// at the start of the rest of the timestamp, or -1 if there isn't one.
+// Terminators are only found where a command would otherwise be read, so
+// values can contain "1999" or "2000" without ending a block, and blocks can
+// be nested in blocks of the same kind.
+func (c *compiler) endingBlock(timestamp string) int {
+	for i := len(c.blocks) - 1; i >= 0; i-- {
+		if strings.HasPrefix(timestamp, c.blocks[i].term) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// skipBlock returns the number of digits to skip after a command that
+// couldn't be decoded. Since it isn't known where the command ends, the rest
+// of the innermost block is skipped, up to the first occurrence of the
+// block's terminator.
+func (c *compiler) skipBlock(timestamp string) int {
+	if len(c.blocks) > 0 {
+		if idx := strings.Index(timestamp, c.blocks[len(c.blocks)-1].term); idx > 0 {
+			return idx
+		}
+	}
+
+	return len(timestamp)
+}
+
+// atElse checks if the else marker ("2001") is at the start of the rest of
+// the innermost block, which starts the else branch of an if block. The
+// marker is only found where a command would otherwise be read, so it can
+// still be used within values. Older programs that have the ignored
+// commands "2 0 0 1" in an if block are run differently, since the
+// commands after them are now the else branch.
+func (c *compiler) atElse(timestamp string) bool {
+	if len(c.blocks) == 0 {
+		return false
+	}
+
+	top := c.blocks[len(c.blocks)-1]
+	return !top.loop && !top.define && top.elseAt < 0 && strings.HasPrefix(timestamp, utils.ElseMarker)
+}
+
+// elseBranch adds the OpElse instruction for the innermost block. The else
+// branch starts with the Digits, Debug, Unicode and Stderr values the block
+// started with, since it's only run if the if branch isn't.
+func (c *compiler) elseBranch() {
+	top := &c.blocks[len(c.blocks)-1]
+	top.elseAt = len(c.program)
+
+	c.Digits = top.digits
+	c.Debug = top.debug
+	c.unicode = top.unicode
+	c.stderr = top.stderr
+	c.program[top.header].Jump = top.elseAt
+	c.program = append(c.program, Instruction{
+		Op:      OpElse,
+		Command: CONDITION,
+		Offset:  c.pos,
+		Size:    len(utils.ElseMarker),
+		Value:   utils.ElseMarker,
+		Digits:  c.Digits,
+		Debug:   c.Debug,
+		Unicode: c.unicode,
+		Stderr:  c.stderr,
+		Jump:    -1,
+	})
+
+	c.pos += len(utils.ElseMarker)
+}
+
+// closeBlock adds the OpEnd instruction for the innermost block. If term is
+// true, the block's terminator is skipped, otherwise the block ended without
+// one (at the end of an outer block, or at the end of the timestamp).
+// Changes made by META commands only apply until the end of the block
+// they're in.
+func (c *compiler) closeBlock(term bool) {
+	top := c.blocks[len(c.blocks)-1]
+	c.blocks = c.blocks[:len(c.blocks)-1]
+
+	c.Digits = top.digits
+	c.Debug = top.debug
+	c.unicode = top.unicode
+	c.stderr = top.stderr
+	if top.elseAt >= 0 {
+		c.program[top.elseAt].Jump = len(c.program)
+	} else {
+		c.program[top.header].Jump = len(c.program)
+	}
+
+	size := 0
+	if term {
+		size = len(top.term)
+	}
+
+	c.program = append(c.program, Instruction{
+		Op:      OpEnd,
+		Command: CONDITION,
+		Offset:  c.pos,
+		Size:    size,
+		Value:   c.timestamp[c.pos : c.pos+size],
+		Digits:  c.Digits,
+		Debug:   c.Debug,
+		Unicode: c.unicode,
+		Stderr:  c.stderr,
+		Jump:    top.header,
+	})
+
+	c.pos += size
+}
+
+// debugAt adds a debug message to an instruction, which is printed each
+// time the instruction is run. The source position of the digit at the
+// given offset is appended to the message (if the position is known).
+func (c *compiler) debugAt(ins *Instruction, offset int, template string, input ...string) {
+	if !c.Debug {
+		return
+	}
+
+	if pos := c.Source.Describe(offset); len(pos) > 0 {
+		template += " (%s)"
+		input = append(input, pos)
+	}
+
+	ins.trace = append(ins.trace, formatMsg(template, input...))
+}
+
+// String describes an instruction using the names of its command and
+// fields, i.e. "PRINT Type=2 Size=1 value=1".
+func (ins *Instruction) String() string {
+	switch ins.Op {
+	case OpEnd:
+		if len(ins.Value) == 0 {
+			// The block ended without a terminator
+			return "END"
+		}
+		return "END " + ins.Value
+	case OpElse:
+		return "ELSE " + ins.Value
+	case OpSkip:
+		return fmt.Sprintf("SKIP %d", ins.Command)
+	case OpError:
+		return fmt.Sprintf("ERROR %s", ins.Err)
+	}
+
+	schema, _ := LookupCommand(ins.Command)
+	desc := schema.Name
+	for i, field := range schema.Fields {
+		desc += fmt.Sprintf(" %s=%d", field.Name, ins.Fields[i])
+	}
+
+	if schema.Chunks != nil {
+		desc += " value=" + ins.Value
+	}
+
+	return desc
+}
+
+// Arg returns the value of one of the instruction's fields by name, or -1 if
+// the instruction's command doesn't have the field.
+func (ins *Instruction) Arg(name string) int {
+	schema, ok := LookupCommand(ins.Command)
+	if !ok || ins.Fields == nil {
+		return -1
+	}
+
+	if idx := schema.Field(name); idx >= 0 {
+		return ins.Fields[idx]
+	}
+
+	return -1
+}
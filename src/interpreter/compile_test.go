@@ -0,0 +1,133 @@
+package interpreter_test
+
+import (
+	"github.com/benbusby/y2k/src/interpreter"
+	"testing"
+)
+
+func TestBlocks(t *testing.T) {
+	runCases(t, []testCase{
+		{
+			name: "if branch",
+			asm: `
+var v1 = 7
+if v1 > 5 {
+  print "big"
+} else {
+  print "small"
+}
+print "done"`,
+			output: "big\ndone\n",
+		},
+		{
+			name: "else branch",
+			asm: `
+var v1 = 3
+if v1 > 5 {
+  print "big"
+} else {
+  print "small"
+}
+print "done"`,
+			output: "small\ndone\n",
+		},
+		{
+			name: "nested blocks",
+			asm: `
+var v1 = 0
+while v1 < 3 {
+  v1 += 1
+  if v1 == 2 {
+    print "two"
+  } else {
+    print v1
+  }
+}`,
+			output: "1\ntwo\n3\n",
+		},
+		{
+			name: "nested loops",
+			asm: `
+var v1 = 0
+while v1 < 2 {
+  v1 += 1
+  var v2 = 0
+  while v2 < 2 {
+    v2 += 1
+    print v1, v2
+  }
+}`,
+			output: "1 1\n1 2\n2 1\n2 2\n",
+		},
+		{
+			name: "terminators in values",
+			asm: `
+var v1 = 2000
+if v1 == 2000 {
+  var v2 = 1999
+  print v2
+}`,
+			output: "1999\n",
+		},
+		{
+			name:   "block ends at the end of the timestamp",
+			raw:    "6 1 1 0 1 0 9 1 1 1",
+			output: "a\n",
+		},
+		{
+			name:   "else marker in an if block that was ignored before",
+			raw:    "6 1 1 0 1 0 9 1 1 1 2 0 0 1 9 1 1 2 2000 9 1 1 3",
+			output: "a\nc\n",
+		},
+		{
+			name:   "else marker outside of a block is ignored",
+			raw:    "2001 9 1 1 1",
+			output: "a\n",
+		},
+		{
+			name: "digits are restored after a block",
+			asm: `
+var v1 = 1
+if v1 == 1 {
+  digits 2
+  print "in"
+}
+print "out"`,
+			output: "in\nout\n",
+		},
+		{
+			name:   "unknown extended command",
+			raw:    "03 10",
+			digits: 2,
+			err:    interpreter.ErrInvalidValue,
+		},
+		{
+			name:   "extended command IDs are ignored without EXTEND",
+			raw:    "35 09 01 01 01",
+			digits: 2,
+			output: "a\n",
+		},
+		{
+			name:   "extended command IDs are ignored with 3 digits",
+			raw:    "101 104 009 001 001 001",
+			digits: 3,
+			output: "a\n",
+		},
+		{
+			name:   "extended commands with 2 digits",
+			raw:    "03 05 01 09 01 01 01 2000 03 06 01",
+			digits: 2,
+			output: "a\n",
+		},
+		{
+			name: "break outside of a loop",
+			raw:  "3 3",
+			err:  interpreter.ErrMisplaced,
+		},
+		{
+			name: "return outside of a subroutine",
+			raw:  "3 7",
+			err:  interpreter.ErrMisplaced,
+		},
+	})
+}
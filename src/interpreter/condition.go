@@ -3,159 +3,253 @@ package interpreter
 import (
 	"github.com/benbusby/y2k/src/utils"
 	"math"
-	"reflect"
+	"math/big"
 	"strings"
 )
 
+// Y2KCompFn is an enum to indicate which comparison a CONDITION or CLAUSE
+// command makes.
+type Y2KCompFn uint8
+
+const (
+	Y2KCompEqual          Y2KCompFn = 1
+	Y2KCompLess           Y2KCompFn = 2
+	Y2KCompGreater        Y2KCompFn = 3
+	Y2KCompDivisible      Y2KCompFn = 4
+	Y2KCompNotEqual       Y2KCompFn = 5
+	Y2KCompLessOrEqual    Y2KCompFn = 6
+	Y2KCompGreaterOrEqual Y2KCompFn = 7
+	Y2KCompNotDivisible   Y2KCompFn = 8
+	Y2KCompStartsWith     Y2KCompFn = 9
+)
+
 // ComparisonMap holds an int->function mapping to compare a variable against
-// an arbitrary value.
-var ComparisonMap = map[uint8]func(*Y2KVar, []string) bool{
-	1: EqualTo,
-	2: LessThan,
-	3: GreaterThan,
-	4: IsDivisible,
+// an arbitrary value (either another variable, or a literal value created by
+// literalVar).
+var ComparisonMap = map[Y2KCompFn]func(*Y2KVar, *Y2KVar) bool{
+	Y2KCompEqual:          EqualTo,
+	Y2KCompLess:           LessThan,
+	Y2KCompGreater:        GreaterThan,
+	Y2KCompDivisible:      IsDivisible,
+	Y2KCompNotEqual:       NotEqualTo,
+	Y2KCompLessOrEqual:    LessOrEqual,
+	Y2KCompGreaterOrEqual: GreaterOrEqual,
+	Y2KCompNotDivisible:   NotDivisible,
+	Y2KCompStartsWith:     StartsWith,
 }
 
-type Y2KCond struct {
-	VarID       uint8
-	CompFn      uint8
-	Loop        bool
-	CompValSize uint8
-	value       string
+// Fields of a CONDITION command
+const (
+	condVarID = iota
+	condCompFn
+	condFlags
+	condValSize
+)
+
+// Flags of a CONDITION command, which are added together to form the Flags
+// field. CLAUSE commands use the same flags, except for CondFlagLoop.
+const (
+	CondFlagLoop   = 1
+	CondFlagIsVar  = 2
+	CondFlagNegate = 4
+)
+
+// Fields of a CLAUSE command. The fields after clauseLogic are the same as
+// those of a CONDITION command.
+const (
+	clauseLogic = iota
+	clauseVarID
+)
+
+// Logic values of a CLAUSE command, which decide how the clause is combined
+// with the comparisons before it.
+const (
+	ClauseAnd = 1
+	ClauseOr  = 2
+)
+
+// comparison is a single comparison of a condition, made by either the
+// CONDITION command or one of the CLAUSE commands that follow it.
+type comparison struct {
+	compFn func(*Y2KVar, *Y2KVar) bool
+	target *Y2KVar
+	arg    *Y2KVar
+	negate bool
+	or     bool
 }
 
-// RunCond evaluates a timestamp as either a loop or a standalone "if" statement.
-// All conditions compare a variable's value against a slice of strings, with the
-// latter getting converted to the variable's data type in the comparison function.
-// For example, if comparing an integer variable against ["8", "9"], the integer
-// would need to have the number 89 stored as its numeric value. If comparing a
-// string variable, it would need to have "hi" stored as its string value.
-func (y2kComp Y2KCond) RunCond(
-	y2k Y2K,
-	timestamp string,
-	target *Y2KVar,
-	splitComp []string,
-) bool {
-	var result string
-
-	if y2kComp.Loop {
-		for ComparisonMap[y2kComp.CompFn](target, splitComp) {
-			result = y2k.Parse(timestamp)
-		}
-	} else {
-		if ComparisonMap[y2kComp.CompFn](target, splitComp) {
-			result = y2k.Parse(timestamp)
-		}
+// test compares the target variable against the comparison value. If the
+// value is another variable, that variable's current value is used.
+func (c *comparison) test() bool {
+	return c.compFn(c.target, c.arg) != c.negate
+}
+
+// compare orders a variable against a value, returning -1 if the variable is
+// less than the value, 1 if it's greater, or 0 if they're equal. Strings are
+// compared lexicographically, and all other types are compared numerically
+// (exactly, if both are integers).
+func compare(y2kVar *Y2KVar, arg *Y2KVar) int {
+	if y2kVar.Type == Y2KString {
+		return strings.Compare(y2kVar.strVal, arg.strVal)
+	} else if bothInts(y2kVar, arg) {
+		return y2kVar.intVal.Cmp(arg.intVal)
 	}
 
-	if result == utils.ContinueCmd {
-		// Break value of timestamp if commanded
-		return true
+	switch {
+	case y2kVar.numVal < arg.numVal:
+		return -1
+	case y2kVar.numVal > arg.numVal:
+		return 1
 	}
 
-	return false
+	return 0
 }
 
 // EqualTo checks string or numeric equality
-func EqualTo(y2kVar *Y2KVar, values []string) bool {
-	if y2kVar.Type == Y2KString {
-		return y2kVar.strVal == utils.StrArrToPrintable(values)
-	}
+func EqualTo(y2kVar *Y2KVar, arg *Y2KVar) bool {
+	return compare(y2kVar, arg) == 0
+}
 
-	return y2kVar.numVal == utils.StrArrToFloat(values)
+// NotEqualTo checks string or numeric inequality
+func NotEqualTo(y2kVar *Y2KVar, arg *Y2KVar) bool {
+	return compare(y2kVar, arg) != 0
 }
 
-// LessThan checks if a string's length is less than the specified value,
+// LessThan checks if a string comes before another string alphabetically,
 // or if a number is less than a different numeric value.
-func LessThan(y2kVar *Y2KVar, values []string) bool {
-	if y2kVar.Type == Y2KString {
-		return len(y2kVar.strVal) < utils.StrArrToInt(values)
-	}
-
-	return y2kVar.numVal < utils.StrArrToFloat(values)
+func LessThan(y2kVar *Y2KVar, arg *Y2KVar) bool {
+	return compare(y2kVar, arg) < 0
 }
 
-// GreaterThan checks if a string's length is greater than the specified value,
+// GreaterThan checks if a string comes after another string alphabetically,
 // or if a number is greater than a different numeric value.
-func GreaterThan(y2kVar *Y2KVar, values []string) bool {
+func GreaterThan(y2kVar *Y2KVar, arg *Y2KVar) bool {
+	return compare(y2kVar, arg) > 0
+}
+
+// LessOrEqual is the opposite of GreaterThan.
+func LessOrEqual(y2kVar *Y2KVar, arg *Y2KVar) bool {
+	return compare(y2kVar, arg) <= 0
+}
+
+// GreaterOrEqual is the opposite of LessThan.
+func GreaterOrEqual(y2kVar *Y2KVar, arg *Y2KVar) bool {
+	return compare(y2kVar, arg) >= 0
+}
+
+// IsDivisible checks if a numeric variable is evenly divisible by a
+// specific number. For strings, this checks if the variable contains
+// another string. Nothing is divisible by 0.
+func IsDivisible(y2kVar *Y2KVar, arg *Y2KVar) bool {
 	if y2kVar.Type == Y2KString {
-		return len(y2kVar.strVal) > utils.StrArrToInt(values)
+		return strings.Contains(y2kVar.strVal, arg.strVal)
+	} else if bothInts(y2kVar, arg) {
+		return arg.intVal.Sign() != 0 &&
+			new(big.Int).Rem(y2kVar.intVal, arg.intVal).Sign() == 0
 	}
 
-	return y2kVar.numVal > utils.StrArrToFloat(values)
+	return math.Mod(y2kVar.numVal, arg.numVal) == 0
 }
 
-// IsDivisible checks if a numeric variable is evenly divisible by a
-// specific number. Currently, there isn't an equivalent for string
-// variables, so this will just return true in that case.
-func IsDivisible(y2kVar *Y2KVar, values []string) bool {
+// NotDivisible is the opposite of IsDivisible.
+func NotDivisible(y2kVar *Y2KVar, arg *Y2KVar) bool {
+	return !IsDivisible(y2kVar, arg)
+}
+
+// StartsWith checks if a string starts with another string. Numbers are
+// checked using their digits, so 1234 starts with 12.
+func StartsWith(y2kVar *Y2KVar, arg *Y2KVar) bool {
 	if y2kVar.Type == Y2KString {
-		return true
+		return strings.HasPrefix(y2kVar.strVal, arg.strVal)
 	}
 
-	return math.Mod(y2kVar.numVal, utils.StrArrToFloat(values)) == 0
+	return strings.HasPrefix(y2kVar.GetValue(), arg.GetValue())
 }
 
-// ParseCondition compares a variable against a raw value and parses a segment of
-// the timestamp until the comparison is false. The segment of the timestamp
-// used for the loop is determined by a function terminator ("1999") or the end
-// of the timestamp if the terminator is not found.
-func (y2k Y2K) ParseCondition(timestamp string, val reflect.Value) string {
-	y2kCond := val.Interface().(Y2KCond)
-
-	input := timestamp[:y2k.Digits]
-	y2k.DebugMsg("ParseCondition: [%s]%s",
-		input,
-		timestamp[y2k.Digits:])
-
-	y2kCond.value += input
-
-	if len(y2kCond.value) >= int(y2kCond.CompValSize) {
-		targetVar := GetVar(y2kCond.VarID)
-
-		// CompFn functions need the raw comparison value passed to
-		// them, because they treat values differently depending on the
-		// target variable data type. It's easier to parse the comparison
-		// value in as a string and then convert it back to a slice of
-		// N-size strings than it is to create the slice during parsing, due
-		// to differences in y2k.Digits values. For example -- parsing a 3
-		// digit number "100XX..." with a 2-digit window would create a
-		// slice of ["10", "0X"], where X is an unrelated digit for a
-		// subsequent command. Parsing it as a string and then splitting it,
-		// however, creates ["10", "0"].
-		splitComp := utils.SplitStrByN(
-			y2kCond.value[:y2kCond.CompValSize],
-			y2k.Digits)
-
-		// Extract the index of the cond terminator and the subset of the
-		// timestamp that should be returned to the main interpreter loop.
-		condTerm := utils.GetCondTerm(y2kCond.Loop)
-		timestampFnTerm := strings.Index(timestamp, condTerm)
-		nextIterTimestamp := timestamp[timestampFnTerm+len(condTerm)-1:]
-
-		// If there isn't a function terminator, assume that the condition
-		// terminates at the end of the timestamp.
-		if timestampFnTerm < 0 {
-			timestampFnTerm = len(timestamp)
-			nextIterTimestamp = ""
+// parseCondition compares a variable against a raw value, and starts a new
+// block for the body of the condition if the comparison is true. Otherwise,
+// the program continues at the block's else branch (if it has one), or after
+// the end of the block. Loops are run again when the end of their block is
+// reached (see machine.endBlock).
+//
+// Any CLAUSE commands directly after the condition are read as part of it,
+// adding more comparisons that are combined with AND or OR.
+func (m *machine) parseCondition(ins *Instruction) error {
+	newBlock := block{
+		header: m.pc,
+		loop:   ins.Fields[condFlags]&CondFlagLoop != 0,
+	}
+
+	cond, err := m.comparison(ins, ins.Fields)
+	if err != nil {
+		return err
+	}
+	newBlock.conds = append(newBlock.conds, cond)
+
+	for m.pc++; m.pc < len(m.program) && m.program[m.pc].Command == CLAUSE; m.pc++ {
+		clause := &m.program[m.pc]
+		for _, msg := range clause.trace {
+			m.OutputMsg(msg)
+		}
+
+		if clause.Op == OpError {
+			return withCommand(clause.Err, CLAUSE)
 		}
 
-		// Determine the segment of the timestamp that will be parsed on
-		// each iteration of the while loop.
-		whileTimestamp := timestamp[y2k.Digits:timestampFnTerm]
-		y2k.DebugMsg(utils.DebugDivider)
+		cond, err := m.comparison(clause, clause.Fields[clauseVarID:])
+		if err != nil {
+			return withCommand(err, CLAUSE)
+		}
 
-		stop := y2kCond.RunCond(y2k, whileTimestamp, targetVar, splitComp)
+		cond.or = clause.Fields[clauseLogic] == ClauseOr
+		newBlock.conds = append(newBlock.conds, cond)
+	}
 
-		// Conditions can optionally break value of the timestamp using the
-		// CONTINUE command (see interpreter.go). In this instance, the next
-		// timestamp passed back to the parser should be empty.
-		if stop {
-			return ""
+	newBlock.body = m.pc
+	if !newBlock.test() {
+		// Skip to the else branch, or past the end of the block
+		m.pc = ins.Jump + 1
+		if m.program[ins.Jump].Op != OpElse {
+			return nil
 		}
+	}
+
+	m.DebugMsg(utils.DebugDivider)
+	m.blocks = append(m.blocks, newBlock)
+
+	return nil
+}
+
+// comparison reads a comparison from the fields of a CONDITION or CLAUSE
+// command, which are ordered as VarID, CompFn, Flags and CompValSize.
+func (m *machine) comparison(ins *Instruction, fields []int) (comparison, error) {
+	value := ins.Value[:fields[condValSize]]
+	cond := comparison{
+		compFn: ComparisonMap[Y2KCompFn(fields[condCompFn])],
+		target: m.GetVar(uint8(fields[condVarID])),
+		negate: fields[condFlags]&CondFlagNegate != 0,
+	}
 
-		return nextIterTimestamp
+	// In the same way as MODIFY, the comparison value is converted to a
+	// variable with both a string and a numeric value, and the comparison
+	// function decides which one to use based on the type of the target
+	// variable (the string value is only read if the target is a string
+	// when the condition starts). If the value is a variable ID, that variable is used
+	// instead, so its current value is read each time the condition is
+	// checked.
+	if fields[condFlags]&CondFlagIsVar != 0 {
+		var err error
+		cond.arg, err = m.varByID(value, ins.valueOffset)
+		if err != nil {
+			return cond, err
+		}
+	} else {
+		var err error
+		cond.arg, err = m.literal(value, ins.valueOffset, ins, cond.target.Type == Y2KString)
+		if err != nil {
+			return cond, err
+		}
 	}
 
-	return y2k.ParseCondition(timestamp[y2k.Digits:], reflect.ValueOf(y2kCond))
+	return cond, nil
 }
@@ -0,0 +1,154 @@
+package interpreter_test
+
+import (
+	"testing"
+)
+
+func TestCondition(t *testing.T) {
+	runCases(t, []testCase{
+		{
+			name: "compare against a variable",
+			asm: `
+var v1 = 3
+var v2 = 3
+if v1 == v2 {
+  print "equal"
+}
+v2 += 1
+if v1 < v2 {
+  print "less"
+}`,
+			output: "equal\nless\n",
+		},
+		{
+			name: "numeric comparisons",
+			asm: `
+var v1 = 5
+if v1 != 4 {
+  print "ne"
+}
+if v1 <= 5 {
+  print "le"
+}
+if v1 >= 6 {
+  print "ge"
+}
+if v1 % 2 != 0 {
+  print "odd"
+}`,
+			output: "ne\nle\nodd\n",
+		},
+		{
+			name: "string comparisons",
+			asm: `
+var v1 = "bcd"
+if v1 > "abc" {
+  print "after"
+}
+if v1 contains "cd" {
+  print "contains"
+}
+if v1 !contains "x" {
+  print "no x"
+}
+if v1 startswith "bc" {
+  print "prefix"
+}`,
+			output: "after\ncontains\nno x\nprefix\n",
+		},
+		{
+			name: "numbers start with their digits",
+			asm: `
+var v1 = 1234
+if v1 startswith 12 {
+  print "yes"
+}`,
+			output: "yes\n",
+		},
+		{
+			name: "and, or and not",
+			asm: `
+var v1 = 3
+if v1 > 1 and v1 < 5 {
+  print "between"
+}
+if v1 == 9 or v1 == 3 {
+  print "either"
+}
+if not v1 == 3 {
+  print "not"
+}
+if v1 == 3 or v1 == 9 and v1 == 8 {
+  print "and first"
+}`,
+			output: "between\neither\nand first\n",
+		},
+		{
+			name: "loop condition is checked with the current value",
+			asm: `
+var v1 = 0
+var v2 = 3
+while v1 < v2 {
+  v1 += 1
+  if v1 == 1 {
+    v2 += 1
+  }
+}
+print v1`,
+			output: "4\n",
+		},
+	})
+}
+
+func TestBreakContinue(t *testing.T) {
+	runCases(t, []testCase{
+		{
+			name: "break",
+			asm: `
+var v1 = 0
+while v1 < 10 {
+  v1 += 1
+  if v1 == 3 {
+    break
+  }
+  print v1
+}
+print "done"`,
+			output: "1\n2\ndone\n",
+		},
+		{
+			name: "continue inside of an if block",
+			asm: `
+var v1 = 0
+while v1 < 4 {
+  v1 += 1
+  if v1 % 2 == 0 {
+    continue
+  }
+  print v1
+}`,
+			output: "1\n3\n",
+		},
+		{
+			name: "break from a nested loop",
+			asm: `
+var v1 = 0
+while v1 < 2 {
+  v1 += 1
+  while v1 < 10 {
+    break
+  }
+  print v1
+}`,
+			output: "1\n2\n",
+		},
+		{
+			name: "continue outside of a loop ends the program",
+			asm: `
+print "a"
+continue
+print "b"`,
+			output: "a\n",
+		},
+	})
+}
@@ -0,0 +1,122 @@
+package interpreter
+
+import (
+	"fmt"
+	"github.com/benbusby/y2k/src/utils"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Y2KConvFn is an enum to indicate which conversion a CONVERT command
+// performs.
+type Y2KConvFn uint8
+
+const (
+	Y2KConvString Y2KConvFn = 1
+	Y2KConvInt    Y2KConvFn = 2
+	Y2KConvFloat  Y2KConvFn = 3
+	Y2KConvRound  Y2KConvFn = 4
+	Y2KConvChar   Y2KConvFn = 5
+	Y2KConvCode   Y2KConvFn = 6
+)
+
+// Fields of a CONVERT command
+const (
+	convVarID = iota
+	convFn
+	convDestID
+)
+
+// parseConvert converts the value of a variable to a different type, and
+// stores it in the destination variable. Using the same variable for both
+// converts the variable in place.
+func (m *machine) parseConvert(ins *Instruction) error {
+	source := m.GetVar(uint8(ins.Fields[convVarID]))
+	id := uint8(ins.Fields[convDestID])
+
+	var newVar *Y2KVar
+	var err error
+	switch Y2KConvFn(ins.Fields[convFn]) {
+	case Y2KConvString:
+		newVar = newString(id, source.GetValue())
+	case Y2KConvInt:
+		newVar, err = toInt(id, source, math.Trunc)
+	case Y2KConvFloat:
+		newVar, err = toFloat(id, source)
+	case Y2KConvRound:
+		newVar, err = toInt(id, source, math.Round)
+	case Y2KConvChar:
+		char, ok := utils.CodeToChar(int(source.numVal), ins.Unicode)
+		if !ok {
+			err = fmt.Errorf("%w: no character for code %s", ErrInvalidValue, source.GetValue())
+			break
+		}
+
+		newVar = newString(id, string(char))
+	case Y2KConvCode:
+		code := -1
+		if chars := []rune(source.GetValue()); len(chars) > 0 {
+			code = utils.CharToCode(chars[0], ins.Unicode)
+		}
+
+		newVar = newInt(id, big.NewInt(int64(code)))
+	}
+
+	if err != nil {
+		return m.errorAt(ins.Offset, "", err)
+	}
+
+	m.setVar(newVar)
+	return nil
+}
+
+// toInt converts a variable to an integer. Strings are read as a number, and
+// numbers that aren't whole are rounded with the given function.
+func toInt(id uint8, source *Y2KVar, round func(float64) float64) (*Y2KVar, error) {
+	if source.intVal != nil {
+		return newInt(id, new(big.Int).Set(source.intVal)), nil
+	}
+
+	numVal := source.numVal
+	if source.Type == Y2KString {
+		text := strings.TrimSpace(source.strVal)
+		if intVal, ok := new(big.Int).SetString(text, 10); ok {
+			return newInt(id, intVal), nil
+		}
+
+		var err error
+		numVal, err = parseNumber(text)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	newVar := &Y2KVar{ID: id, Type: Y2KInt}
+	return newVar, newVar.setNumber(round(numVal))
+}
+
+// toFloat converts a variable to a float. Strings are read as a number.
+func toFloat(id uint8, source *Y2KVar) (*Y2KVar, error) {
+	numVal := source.numVal
+	if source.Type == Y2KString {
+		var err error
+		numVal, err = parseNumber(strings.TrimSpace(source.strVal))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Y2KVar{ID: id, Type: Y2KFloat, numVal: numVal}, nil
+}
+
+// parseNumber reads the text of a string variable as a number.
+func parseNumber(text string) (float64, error) {
+	numVal, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsNaN(numVal) || math.IsInf(numVal, 0) {
+		return 0, fmt.Errorf("%w: %q is not a number", ErrInvalidValue, text)
+	}
+
+	return numVal, nil
+}
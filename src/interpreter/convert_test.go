@@ -0,0 +1,58 @@
+package interpreter_test
+
+import (
+	"github.com/benbusby/y2k/src/interpreter"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	runCases(t, []testCase{
+		{
+			name: "strings to numbers",
+			asm: `
+var v1 = "12"
+convert v1 int v2
+v2 += 1
+print v2
+var v3 = "2.5"
+convert v3 float v4
+v4 *= 2
+print v4
+convert v3 int v4
+print v4
+convert v3 round v4
+print v4`,
+			output: "13\n5\n2\n3\n",
+		},
+		{
+			name: "numbers to strings",
+			asm: `
+var v1 = 12
+convert v1 string
+v1 += "3"
+print v1`,
+			output: "123\n",
+		},
+		{
+			name: "characters and codes",
+			asm: `
+var v1 = "b"
+convert v1 code v2
+print v2
+v2 += 1
+convert v2 char
+print v2`,
+			output: "2\nc\n",
+		},
+		{
+			name: "not a number",
+			asm:  "var v1 = \"abc\"\nconvert v1 int",
+			err:  interpreter.ErrInvalidValue,
+		},
+		{
+			name: "no character for a code",
+			asm:  "var v1 = 999\nconvert v1 char",
+			err:  interpreter.ErrInvalidValue,
+		},
+	})
+}
@@ -0,0 +1,130 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/benbusby/y2k/src/utils"
+)
+
+var (
+	ErrUnexpectedEnd = errors.New("unexpected end of timestamp")
+	ErrInvalidDigit  = errors.New("invalid digit")
+	ErrInvalidDigits = errors.New("digits must be greater than 0")
+	ErrInvalidValue  = errors.New("invalid value")
+	ErrMisplaced     = errors.New("command can't be used here")
+	ErrCallDepth     = errors.New("subroutine calls are nested too deeply")
+)
+
+// Error is returned by Parse when a program can't be decoded or run. It
+// records where the problem occurred, both as a digit offset in the
+// concatenated timestamp and as the file position the digit was read from.
+// If the problem occurred inside of a condition, Trace lists the enclosing
+// conditions from innermost to outermost.
+type Error struct {
+	Offset  int
+	Pos     utils.Position
+	Command string
+	Field   string
+	Err     error
+	Trace   []TraceFrame
+}
+
+// TraceFrame is a command that was being run when an Error occurred.
+type TraceFrame struct {
+	Offset  int
+	Pos     utils.Position
+	Command string
+}
+
+func (e *Error) Error() string {
+	name := e.Command
+	if len(e.Field) > 0 {
+		if len(name) > 0 {
+			name += "."
+		}
+		name += e.Field
+	}
+
+	msg := fmt.Sprintf("%s: %s", formatOffset(e.Offset, e.Pos), e.Err)
+	if len(name) > 0 {
+		msg = fmt.Sprintf("%s: %s: %s", formatOffset(e.Offset, e.Pos), name, e.Err)
+	}
+
+	// Repeated frames (from a subroutine calling itself) are only listed
+	// once
+	for i := 0; i < len(e.Trace); i++ {
+		frame := e.Trace[i]
+		msg += fmt.Sprintf("\n\tin %s at %s",
+			frame.Command,
+			formatOffset(frame.Offset, frame.Pos))
+
+		repeats := 0
+		for i+1 < len(e.Trace) && e.Trace[i+1] == frame {
+			repeats++
+			i++
+		}
+
+		if repeats > 0 {
+			msg += fmt.Sprintf(" (repeated %d more times)", repeats)
+		}
+	}
+
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ExitError is returned by Run when a program is stopped by an EXIT command
+// with a non-zero status code.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// formatOffset describes a digit offset, along with the file position it was
+// read from if known.
+func formatOffset(offset int, pos utils.Position) string {
+	if len(pos.File) > 0 {
+		return fmt.Sprintf("offset %d (%s)", offset, pos)
+	}
+
+	return fmt.Sprintf("offset %d", offset)
+}
+
+// errorAt creates an Error for the digit at the given offset, using the
+// interpreter's source map (if set) to determine where the digit came from.
+func (y2k Y2K) errorAt(offset int, field string, err error) *Error {
+	pos, _ := y2k.Source.Lookup(offset)
+	return &Error{Offset: offset, Pos: pos, Field: field, Err: err}
+}
+
+// withCommand sets the command name on an Error that doesn't have one yet.
+func withCommand(err error, command Y2KCommand) error {
+	var y2kErr *Error
+	if errors.As(err, &y2kErr) && len(y2kErr.Command) == 0 {
+		y2kErr.Command = command.String()
+	}
+
+	return err
+}
+
+// withTrace adds a command that was running when an Error occurred to the
+// Error's trace.
+func (y2k Y2K) withTrace(err error, command Y2KCommand, offset int) error {
+	var y2kErr *Error
+	if errors.As(err, &y2kErr) {
+		pos, _ := y2k.Source.Lookup(offset)
+		y2kErr.Trace = append(y2kErr.Trace, TraceFrame{
+			Offset:  offset,
+			Pos:     pos,
+			Command: command.String(),
+		})
+	}
+
+	return err
+}
@@ -0,0 +1,46 @@
+package interpreter
+
+import (
+	"fmt"
+	"github.com/benbusby/y2k/src/utils"
+	"math"
+)
+
+// MaxExitCode is the largest status code that an EXIT command can use.
+const MaxExitCode = 255
+
+// Fields of an EXIT command
+const (
+	exitCodeIsVar = iota
+	exitCodeSize
+)
+
+// parseExit ends the program with the status code in the value of the
+// command (or in the variable with the ID in the value). An empty value
+// ends the program with status 0, in the same way as reaching the end of
+// the timestamp.
+func (m *machine) parseExit(ins *Instruction) error {
+	value := ins.Value[:ins.Fields[exitCodeSize]]
+	code := utils.StrArrToFloat(utils.SplitStrByN(value, ins.Digits))
+	if ins.Fields[exitCodeIsVar] != 0 {
+		codeVar, err := m.varByID(value, ins.valueOffset)
+		if err != nil {
+			return err
+		}
+
+		code = codeVar.numVal
+	}
+
+	if code < 0 || code > MaxExitCode || code != math.Trunc(code) {
+		return m.errorAt(ins.valueOffset, "value", fmt.Errorf(
+			"%w: exit status %v isn't a whole number from 0 to %d",
+			ErrInvalidValue,
+			code,
+			MaxExitCode))
+	}
+
+	m.halted = true
+	m.status = int(code)
+
+	return nil
+}
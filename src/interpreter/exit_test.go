@@ -0,0 +1,50 @@
+package interpreter_test
+
+import (
+	"github.com/benbusby/y2k/src/interpreter"
+	"testing"
+)
+
+func TestExit(t *testing.T) {
+	runCases(t, []testCase{
+		{
+			name:   "status code",
+			asm:    "print \"a\"\nexit 3\nprint \"b\"",
+			output: "a\n",
+			exit:   3,
+		},
+		{
+			name:   "status 0",
+			asm:    "exit\nprint \"b\"",
+			output: "",
+		},
+		{
+			name: "variable status code",
+			asm: `
+var v1 = 0
+while v1 < 10 {
+  v1 += 1
+  if v1 == 4 {
+    exit v1
+  }
+}`,
+			exit: 4,
+		},
+		{
+			name: "status code out of range",
+			raw:  "3 0 0 3 300",
+			err:  interpreter.ErrInvalidValue,
+		},
+		{
+			name: "stderr",
+			asm: `
+print "out"
+output stderr
+print "err"
+output stdout
+print "out"`,
+			output: "out\nout\n",
+			stderr: "err\n",
+		},
+	})
+}
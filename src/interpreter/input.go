@@ -0,0 +1,91 @@
+package interpreter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Y2KInputMode is an enum to indicate how much input an INPUT command reads.
+type Y2KInputMode uint8
+
+const (
+	Y2KInputLine   Y2KInputMode = 1
+	Y2KInputNumber Y2KInputMode = 2
+)
+
+// Fields of an INPUT command
+const (
+	inputVarID = iota
+	inputMode
+)
+
+// parseInput reads from the interpreter's input into a variable. Lines have
+// their type inferred in the same way as command line arguments, and numbers
+// are read one whitespace-separated word at a time. Once the input has run
+// out, the variable is set to an empty string.
+func (m *machine) parseInput(ins *Instruction) error {
+	id := uint8(ins.Fields[inputVarID])
+
+	var input string
+	var err error
+	switch Y2KInputMode(ins.Fields[inputMode]) {
+	case Y2KInputLine:
+		input, err = m.readLine()
+	case Y2KInputNumber:
+		input, err = m.readWord()
+	}
+
+	if err == io.EOF {
+		m.DebugMsg("INPUT: end of input")
+		m.setVar(&Y2KVar{ID: id, Type: Y2KString})
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	newVar := inferVar(id, input)
+	if Y2KInputMode(ins.Fields[inputMode]) == Y2KInputNumber && newVar.Type == Y2KString {
+		return m.errorAt(ins.Offset, "", fmt.Errorf(
+			"%w: %q is not a number",
+			ErrInvalidValue,
+			input))
+	}
+
+	m.DebugMsg("INPUT: %s", input)
+	m.setVar(newVar)
+
+	return nil
+}
+
+// readLine reads the next line of input, without its line ending. A last
+// line that doesn't end in a newline is still returned.
+func (m *machine) readLine() (string, error) {
+	line, err := m.in.ReadString('\n')
+	if len(line) > 0 && err == io.EOF {
+		err = nil
+	}
+
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// readWord skips any whitespace in the input, and then reads until the next
+// whitespace character.
+func (m *machine) readWord() (string, error) {
+	var word strings.Builder
+	for {
+		c, _, err := m.in.ReadRune()
+		if err == io.EOF && word.Len() > 0 {
+			return word.String(), nil
+		} else if err != nil {
+			return "", err
+		}
+
+		if !unicode.IsSpace(c) {
+			word.WriteRune(c)
+		} else if word.Len() > 0 {
+			return word.String(), nil
+		}
+	}
+}
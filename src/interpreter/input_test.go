@@ -0,0 +1,33 @@
+package interpreter_test
+
+import (
+	"testing"
+)
+
+func TestInput(t *testing.T) {
+	runCases(t, []testCase{
+		{
+			name: "lines",
+			asm: `
+input v1
+input v2
+v2 += 1
+print v1
+print v2
+input v3
+print v3`,
+			input:  "some text\n41\n",
+			output: "some text\n42\n\n",
+		},
+		{
+			name: "numbers",
+			asm: `
+input v1 number
+input v2 number
+v1 += v2
+print v1`,
+			input:  "1.5 2\n",
+			output: "3.5\n",
+		},
+	})
+}
@@ -4,18 +4,26 @@ import (
 	"bufio"
 	"fmt"
 	"github.com/benbusby/y2k/src/utils"
+	"io"
 	"os"
-	"reflect"
 )
 
+// Y2K holds the state of a single interpreter instance. Each instance has its
+// own variable map and its own input/output streams, so multiple programs can
+// be run side by side in the same process. Use New to create one.
 type Y2K struct {
 	Debug  bool
 	Digits int
-}
 
-type Instruction struct {
-	val reflect.Value
-	fn  func(Y2K, string, reflect.Value) string
+	// Source is an optional map of where each digit of the timestamp was
+	// read from, which is used to add file positions to errors.
+	Source *utils.SourceMap
+
+	vars   map[uint8]*Y2KVar
+	args   []string
+	in     *bufio.Reader
+	out    *bufio.Writer
+	errOut io.Writer
 }
 
 type Y2KCommand uint8
@@ -27,71 +35,77 @@ const (
 	CONDITION Y2KCommand = 6
 	META      Y2KCommand = 5
 	CONTINUE  Y2KCommand = 4
+	EXTEND    Y2KCommand = 3
+)
+
+// Extended commands are written as the EXTEND command followed by one chunk
+// holding the extended command's ID, so INPUT is written as "3 1" when
+// parsing 1 digit at a time (or "03 01" when parsing 2 digits at a time).
+// Since the ID is a single chunk, only IDs 0-9 can be written when parsing
+// 1 digit at a time, and all of them are in use. Any further extended
+// commands would need 2 or more digits to be run, so their command IDs start
+// at extendedBase to leave room for IDs above 9.
+const extendedBase Y2KCommand = 100
+
+const (
+	EXIT    Y2KCommand = extendedBase + 0
+	INPUT   Y2KCommand = extendedBase + 1
+	CLAUSE  Y2KCommand = extendedBase + 2
+	BREAK   Y2KCommand = extendedBase + 3
+	LIST    Y2KCommand = extendedBase + 4
+	DEFINE  Y2KCommand = extendedBase + 5
+	CALL    Y2KCommand = extendedBase + 6
+	RETURN  Y2KCommand = extendedBase + 7
+	STRING  Y2KCommand = extendedBase + 8
+	CONVERT Y2KCommand = extendedBase + 9
+)
+
+// Fields of a META command, which replace the Debug, Unicode, Stderr and
+// Digits values of the interpreter for the remainder of the current block.
+const (
+	metaFlags = iota
+	metaDigits
 )
 
-var instMap map[Y2KCommand]Instruction
-var stdout = bufio.NewWriter(os.Stdout)
-
-// CreateStruct uses reflection to form a struct from N-sized chunks
-// from the timestamp. The struct that is constructed is mapped to
-// a Y2KCommand and holds all values that are relevant to performing
-// the specified command (i.e. Y2KVar establishes variable ID,
-// size, and type).
-func (y2k Y2K) CreateStruct(
-	timestamp string,
-	v reflect.Value,
-) (reflect.Value, string) {
-	modFields := 0
-
-	for i := 0; i < v.NumField(); i++ {
-		// Ignore private struct fields
-		if !v.Field(i).CanSet() {
-			continue
-		}
-
-		idx := y2k.Digits * modFields
-		val := utils.StrToInt(timestamp[idx : idx+y2k.Digits])
-
-		// Fetching the string names of the struct name and fields is
-		// pretty expensive, so we're checking debug here before evaluating
-		if y2k.Debug {
-			structName := v.Type().Name()
-			fieldName := v.Type().Field(i).Name
-
-			y2k.DebugMsg("%s.%s: [%s]%s",
-				structName,
-				fieldName,
-				timestamp[idx:idx+y2k.Digits],
-				timestamp[idx+y2k.Digits:],
-			)
-		}
-
-		switch v.Field(i).Type().Kind() {
-		case reflect.Int:
-			fallthrough
-		case reflect.Int8:
-			v.Field(i).SetInt(int64(val))
-			break
-		case reflect.Uint:
-			fallthrough
-		case reflect.Uint8:
-			v.Field(i).SetUint(uint64(val))
-			break
-		case reflect.Bool:
-			v.Field(i).SetBool(val != 0)
-			break
-		default:
-			panic(fmt.Sprintf(
-				"Unhandled type reflection: %s (in %s)",
-				v.Field(i).Type().Kind(),
-				v.String()))
-		}
-
-		modFields += 1
+// Flags of a META command, which are added together to form the Flags field.
+// Since the field used to only turn debug mode on or off, older programs
+// are read in the same way.
+const (
+	MetaFlagDebug   = 1
+	MetaFlagUnicode = 2
+	MetaFlagStderr  = 4
+)
+
+// extendID is the only field of an EXTEND command, which holds the ID of the
+// extended command to run.
+const extendID = 0
+
+// New creates a Y2K interpreter that reads input from in, and writes both
+// program output and debug messages to out. Output that programs print to
+// stderr is written to os.Stderr, unless it's changed with SetErrOutput.
+func New(digits int, debug bool, in io.Reader, out io.Writer) *Y2K {
+	return &Y2K{
+		Debug:  debug,
+		Digits: digits,
+		vars:   map[uint8]*Y2KVar{},
+		in:     bufio.NewReader(in),
+		out:    bufio.NewWriter(out),
+		errOut: os.Stderr,
+	}
+}
+
+// SetErrOutput changes where output that programs print to stderr is
+// written.
+func (y2k *Y2K) SetErrOutput(errOut io.Writer) {
+	y2k.errOut = errOut
+}
+
+func (command Y2KCommand) String() string {
+	if schema, ok := LookupCommand(command); ok {
+		return schema.Name
 	}
 
-	newStart := y2k.Digits * modFields
-	return v, timestamp[newStart:]
+	return fmt.Sprintf("Y2KCommand(%d)", command)
 }
 
 // DebugMsg is used for printing useful info about what operations the
@@ -99,71 +113,68 @@ func (y2k Y2K) CreateStruct(
 // that are being interpreted.
 func (y2k Y2K) DebugMsg(template string, input ...string) {
 	if y2k.Debug {
-		args := make([]interface{}, len(input))
-		for i, s := range input {
-			args[i] = s
-		}
-		y2k.OutputMsg(fmt.Sprintf(template, args...))
+		y2k.OutputMsg(formatMsg(template, input...))
 	}
 }
 
-// OutputMsg uses a buffered stdout writer to output messages from Y2K. It's
-// slightly more performant than fmt.Println.
+// formatMsg formats a message template with a list of string inputs.
+func formatMsg(template string, input ...string) string {
+	args := make([]interface{}, len(input))
+	for i, s := range input {
+		args[i] = s
+	}
+
+	return fmt.Sprintf(template, args...)
+}
+
+// OutputMsg uses the interpreter's buffered output writer to output messages
+// from Y2K. It's slightly more performant than fmt.Println. Write errors are
+// kept by the writer and returned once Parse finishes.
 func (y2k Y2K) OutputMsg(msg string) {
-	defer func(stdout *bufio.Writer) {
-		err := stdout.Flush()
-		utils.Check(err)
-	}(stdout)
-
-	_, err := stdout.WriteString(msg)
-	_, err = stdout.WriteString("\n")
-	utils.Check(err)
+	y2k.Output(msg + "\n")
 }
 
-// Parse manages interpreter state and hands off timestamp parsing to the
-// appropriate function when changes to interpreter state are made.
-// For example, creation of a variable jumps from STANDBY to CREATE states,
-// and moves timestamp parsing to ParseVariable until that function passes
-// parsing back to Parse.
-func (y2k Y2K) Parse(timestamp string) string {
-	// Extract a portion of the timestamp, with size determined by the
-	// Y2K.Digits field.
-	y2k.DebugMsg("Parse: [%s]%s",
-		timestamp[:y2k.Digits],
-		timestamp[y2k.Digits:],
-	)
-	command := Y2KCommand(utils.StrToInt(timestamp[:y2k.Digits]))
-
-	if command == CONTINUE {
-		// Return early if a "continue" command is received
-		return utils.ContinueCmd
-	} else if instruction, ok := instMap[command]; ok {
-		var y2kStruct reflect.Value
-		y2kStruct, timestamp = y2k.CreateStruct(
-			timestamp[y2k.Digits:],
-			instruction.val)
-		timestamp = instruction.fn(y2k, timestamp, y2kStruct)
-	}
+// Output writes a message in the same way as OutputMsg, but without a
+// trailing newline.
+func (y2k Y2K) Output(msg string) {
+	_, _ = y2k.out.WriteString(msg)
+	_ = y2k.out.Flush()
+}
+
+// ErrOutput writes a message to the interpreter's stderr output. Anything
+// written to the regular output is flushed first, so that the two stay in
+// order when they're written to the same place.
+func (y2k Y2K) ErrOutput(msg string) {
+	_ = y2k.out.Flush()
+	_, _ = io.WriteString(y2k.errOut, msg)
+}
 
-	if y2k.Digits > len(timestamp)-y2k.Digits {
-		// Finished parsing
-		return ""
+// Parse interprets a full timestamp. If the timestamp can't be decoded or
+// an instruction can't be performed, an *Error is returned describing where
+// in the timestamp the problem occurred.
+func (y2k Y2K) Parse(timestamp string) error {
+	program, err := y2k.Compile(timestamp)
+	if err != nil {
+		return err
 	}
 
-	return y2k.Parse(timestamp[y2k.Digits:])
+	return y2k.Run(program)
 }
 
-func (y2k Y2K) ParseMeta(timestamp string, val reflect.Value) string {
-	newY2K := val.Interface().(Y2K)
-	return newY2K.Parse(timestamp)
-}
+// Run runs a program that was decoded with Compile. The same program can be
+// run more than once, but variables are kept between runs. If the program
+// is ended by an EXIT command with a non-zero status code, an *ExitError
+// is returned.
+func (y2k Y2K) Run(program *Program) error {
+	m := &machine{Y2K: y2k, program: program.Instructions}
+	err := m.run()
+	if err != nil {
+		return err
+	}
 
-func init() {
-	instMap = map[Y2KCommand]Instruction{
-		PRINT:     {reflect.ValueOf(&Y2KPrint{}).Elem(), Y2K.ParsePrint},
-		CREATE:    {reflect.ValueOf(&Y2KVar{}).Elem(), Y2K.ParseVariable},
-		MODIFY:    {reflect.ValueOf(&Y2KMod{}).Elem(), Y2K.ParseModify},
-		CONDITION: {reflect.ValueOf(&Y2KCond{}).Elem(), Y2K.ParseCondition},
-		META:      {reflect.ValueOf(&Y2K{}).Elem(), Y2K.ParseMeta},
+	if err := y2k.out.Flush(); err != nil {
+		return err
 	}
+
+	return m.exitErr()
 }
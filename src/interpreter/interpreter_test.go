@@ -0,0 +1,321 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/benbusby/y2k/src/asm"
+	"github.com/benbusby/y2k/src/interpreter"
+	"github.com/benbusby/y2k/src/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testCase is a program to run, along with what it should print and how it
+// should end. Programs are written in Y2K assembly (asm), or as raw digits
+// (raw) when the assembler can't write them, with spaces between the digits
+// ignored.
+type testCase struct {
+	name   string
+	asm    string
+	raw    string
+	digits int
+	args   []string
+	input  string
+	output string
+	stderr string
+	err    error
+	exit   int
+}
+
+// runCases runs each test case as a subtest.
+func runCases(t *testing.T, cases []testCase) {
+	t.Helper()
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.check(t)
+		})
+	}
+}
+
+// run runs the program of a test case, and returns what it printed to
+// stdout and stderr.
+func (tc testCase) run(t *testing.T) (string, string, error) {
+	t.Helper()
+
+	digits := tc.digits
+	if digits == 0 {
+		digits = 1
+	}
+
+	timestamp := strings.Join(strings.Fields(tc.raw), "")
+	if len(tc.asm) > 0 {
+		out, err := asm.Assemble(strings.NewReader(tc.asm), digits)
+		if err != nil {
+			t.Fatalf("can't assemble program: %v", err)
+		}
+		timestamp = out.Timestamp
+	}
+
+	var stdout, stderr bytes.Buffer
+	y2k := interpreter.New(digits, false, strings.NewReader(tc.input), &stdout)
+	y2k.SetErrOutput(&stderr)
+	for _, arg := range tc.args {
+		y2k.FromCLIArg(arg)
+	}
+
+	err := y2k.Parse(timestamp)
+	return stdout.String(), stderr.String(), err
+}
+
+// check runs the program of a test case, and compares the results against
+// the expected ones.
+func (tc testCase) check(t *testing.T) {
+	t.Helper()
+
+	stdout, stderr, err := tc.run(t)
+
+	var exitErr *interpreter.ExitError
+	switch {
+	case tc.err != nil:
+		if !errors.Is(err, tc.err) {
+			t.Errorf("error = %v, want %v", err, tc.err)
+		}
+	case tc.exit != 0:
+		if !errors.As(err, &exitErr) || exitErr.Code != tc.exit {
+			t.Errorf("error = %v, want exit status %d", err, tc.exit)
+		}
+	case err != nil:
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if stdout != tc.output {
+		t.Errorf("output = %q, want %q", stdout, tc.output)
+	}
+	if stderr != tc.stderr {
+		t.Errorf("stderr = %q, want %q", stderr, tc.stderr)
+	}
+}
+
+func TestParse(t *testing.T) {
+	runCases(t, []testCase{
+		{
+			name:   "print string",
+			raw:    "9 1 2 8 9",
+			output: "hi\n",
+		},
+		{
+			name:   "create and print",
+			raw:    "8 1 2 2 42 9 2 1 1",
+			output: "42\n",
+		},
+		{
+			name:   "modify",
+			raw:    "8 1 2 2 40 7 1 1 0 1 2 9 2 1 1",
+			output: "42\n",
+		},
+		{
+			name:   "command line arguments",
+			raw:    "9 2 1 9 9 2 1 8",
+			args:   []string{"first", "2"},
+			output: "first\n2\n",
+		},
+		{
+			name:   "change digits",
+			raw:    "5 0 2 09 01 02 08 09",
+			output: "hi\n",
+		},
+		{
+			name: "missing fields",
+			raw:  "8 1 2",
+			err:  interpreter.ErrUnexpectedEnd,
+		},
+		{
+			name: "zero digits",
+			raw:  "5 0 0",
+			err:  interpreter.ErrInvalidDigits,
+		},
+		{
+			name:   "variable ID out of range",
+			raw:    "009 002 001 256",
+			digits: 3,
+			err:    interpreter.ErrInvalidValue,
+		},
+		{
+			name:   "compare against a variable ID out of range",
+			raw:    "006 001 001 002 003 256",
+			digits: 3,
+			err:    interpreter.ErrInvalidValue,
+		},
+		{
+			name: "invalid field",
+			raw:  "8 1 7 1 1",
+			err:  interpreter.ErrInvalidValue,
+		},
+	})
+}
+
+func TestNewSeparateInstances(t *testing.T) {
+	var first, second bytes.Buffer
+	y2k := interpreter.New(1, false, strings.NewReader(""), &first)
+	other := interpreter.New(1, false, strings.NewReader(""), &second)
+
+	if err := y2k.Parse("81215"); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Parse("9211"); err != nil {
+		t.Fatal(err)
+	}
+	if err := y2k.Parse("9211"); err != nil {
+		t.Fatal(err)
+	}
+
+	if first.String() != "5\n" {
+		t.Errorf("first output = %q, want %q", first.String(), "5\n")
+	}
+	if second.String() != "0\n" {
+		t.Errorf("second output = %q, want %q", second.String(), "0\n")
+	}
+}
+
+func TestCompileRunTwice(t *testing.T) {
+	var out bytes.Buffer
+	y2k := interpreter.New(1, false, strings.NewReader(""), &out)
+
+	program, err := y2k.Compile("7110119211")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := y2k.Run(program); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if out.String() != "1\n2\n" {
+		t.Errorf("output = %q, want %q", out.String(), "1\n2\n")
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.y2k")
+	raw := "# Remove too many characters\n8 1 1 3 1 2 3\n6 1 4 0 1 1\n  7 1 2 0 1 5\n"
+	if err := os.WriteFile(path, []byte(raw), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp, source, err := utils.GetTimestamps(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	y2k := interpreter.New(1, false, strings.NewReader(""), &bytes.Buffer{})
+	y2k.Source = source
+	err = y2k.Parse(timestamp)
+
+	var y2kErr *interpreter.Error
+	if !errors.As(err, &y2kErr) {
+		t.Fatalf("error = %v, want an *interpreter.Error", err)
+	}
+
+	// The error is reported at the MODIFY command's value
+	if y2kErr.Command != "MODIFY" || y2kErr.Pos.Line != 4 || y2kErr.Pos.Column != 13 {
+		t.Errorf("error at %s line %d, column %d, want MODIFY line 4, column 13",
+			y2kErr.Command,
+			y2kErr.Pos.Line,
+			y2kErr.Pos.Column)
+	}
+
+	if len(y2kErr.Trace) != 1 || y2kErr.Trace[0].Command != "CONDITION" || y2kErr.Trace[0].Pos.Line != 3 {
+		t.Errorf("trace = %+v, want the CONDITION on line 3", y2kErr.Trace)
+	}
+}
+
+func TestVarLen(t *testing.T) {
+	program, err := asm.Assemble(strings.NewReader(`
+var v1 = "ab"
+v1 *= 3
+var v2 = 5
+v2 **= 10
+var v3 = [v1, v2]
+list v3 append v2
+var v4 = 1.25
+v5 += 15`), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	y2k := interpreter.New(1, false, strings.NewReader(""), &bytes.Buffer{})
+	if err := y2k.Parse(program.Timestamp); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sizes follow the current value, rather than the size the variable
+	// was created with
+	for id, want := range map[uint8]int{1: 6, 2: 7, 3: 3, 4: 3, 5: 2} {
+		if got := y2k.GetVar(id).Len(); got != want {
+			t.Errorf("v%d length = %d, want %d", id, got, want)
+		}
+	}
+}
+
+func TestUnknownCharacterCodes(t *testing.T) {
+	// Each program uses 2 digit parsing, where 99 isn't a character code
+	tests := []struct {
+		name   string
+		raw    string
+		offset int
+	}{
+		{name: "print a string", raw: "09 01 02 99 08", offset: 6},
+		{name: "print separator", raw: "09 05 01 99 01", offset: 6},
+		{name: "create a string", raw: "08 01 01 01 99", offset: 8},
+		{name: "add to a string", raw: "08 01 01 01 01 07 01 01 00 02 99", offset: 20},
+		{name: "compare a string", raw: "08 01 01 01 01 06 01 01 00 02 99", offset: 20},
+		{name: "split a string", raw: "08 01 01 01 01 03 08 01 09 02 00 02 99", offset: 24},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := testCase{raw: tc.raw, digits: 2}.run(t)
+
+			var y2kErr *interpreter.Error
+			if !errors.As(err, &y2kErr) || !errors.Is(err, interpreter.ErrInvalidValue) {
+				t.Fatalf("error = %v, want an invalid value error", err)
+			}
+			if y2kErr.Offset != tc.offset {
+				t.Errorf("error at offset %d, want %d", y2kErr.Offset, tc.offset)
+			}
+		})
+	}
+
+	// Codes without a character are still valid numbers
+	runCases(t, []testCase{
+		{
+			name:   "add to a number",
+			raw:    "08 01 02 02 05 07 01 01 00 02 99 09 02 01 01",
+			digits: 2,
+			output: "104\n",
+		},
+		{
+			name:   "compare a number",
+			raw:    "08 01 02 02 05 06 01 02 00 02 99 09 01 01 01",
+			digits: 2,
+			output: "a\n",
+		},
+		{
+			name:   "repeat a string",
+			raw:    "08 01 01 01 01 07 01 03 00 02 99 09 02 01 01",
+			digits: 2,
+			output: strings.Repeat("a", 99) + "\n",
+		},
+		{
+			name:   "create an empty string",
+			asm:    "var v1 = \"\"\nstring v1 length v2\nprint v2",
+			output: "0\n",
+		},
+	})
+}
@@ -0,0 +1,142 @@
+package interpreter
+
+import (
+	"fmt"
+	"github.com/benbusby/y2k/src/utils"
+	"math/big"
+	"sort"
+)
+
+// Y2KListFn is an enum to indicate which operation a LIST command performs.
+type Y2KListFn uint8
+
+const (
+	Y2KListAppend Y2KListFn = 1
+	Y2KListGet    Y2KListFn = 2
+	Y2KListSet    Y2KListFn = 3
+	Y2KListLength Y2KListFn = 4
+	Y2KListRemove Y2KListFn = 5
+	Y2KListSort   Y2KListFn = 6
+)
+
+// Fields of a LIST command
+const (
+	listID = iota
+	listFn
+	listVarID
+	listIndexIsVar
+	listIndexSize
+)
+
+// createList creates a list from the value of a CREATE command, which holds
+// the ID of each variable to copy into the list (one chunk per variable).
+func (m *machine) createList(ins *Instruction) error {
+	id := uint8(ins.Fields[varID])
+	newList := &Y2KVar{ID: id, Type: Y2KList, items: []*Y2KVar{}}
+
+	chunks := utils.SplitStrByN(ins.Value, ins.Digits)
+	for i, chunk := range chunks[:ins.Fields[varSize]] {
+		item, err := m.varByID(chunk, ins.valueOffset+i*ins.Digits)
+		if err != nil {
+			return err
+		}
+
+		newList.items = append(newList.items, item.clone(item.ID))
+	}
+
+	newList.numVal = float64(len(newList.items))
+	m.vars[id] = newList
+
+	return nil
+}
+
+// argsList creates a list of the command line arguments that were given to
+// the program, in the order they were given. The type of each item is
+// inferred in the same way as the variables created for each argument, and
+// each item uses the ID of the list, since there can be more items than
+// variable IDs.
+func (m *machine) argsList(id uint8) *Y2KVar {
+	newList := &Y2KVar{ID: id, Type: Y2KList, items: []*Y2KVar{}}
+	for _, arg := range m.args {
+		newList.items = append(newList.items, inferVar(id, arg))
+	}
+
+	newList.numVal = float64(len(newList.items))
+	return newList
+}
+
+// parseList performs an operation on a list variable. The value of the
+// command is the index of the item to use (or the ID of a variable holding
+// the index), which is only used by the get, set and remove operations.
+func (m *machine) parseList(ins *Instruction) error {
+	list := m.GetVar(uint8(ins.Fields[listID]))
+	if list.Type != Y2KList {
+		return m.errorAt(ins.Offset, "", fmt.Errorf(
+			"%w: v%d is not a list",
+			ErrInvalidValue,
+			list.ID))
+	}
+
+	value := ins.Value[:ins.Fields[listIndexSize]]
+	index := int(utils.StrArrToFloat(utils.SplitStrByN(value, ins.Digits)))
+	if ins.Fields[listIndexIsVar] != 0 {
+		indexVar, err := m.varByID(value, ins.valueOffset)
+		if err != nil {
+			return err
+		}
+
+		index = int(indexVar.numVal)
+	}
+
+	fn := Y2KListFn(ins.Fields[listFn])
+	if (fn == Y2KListGet || fn == Y2KListSet || fn == Y2KListRemove) &&
+		(index < 0 || index >= len(list.items)) {
+		return m.errorAt(ins.valueOffset, "value", fmt.Errorf(
+			"%w: index %d is out of range for a list of length %d",
+			ErrInvalidValue,
+			index,
+			len(list.items)))
+	}
+
+	id := uint8(ins.Fields[listVarID])
+	var result *Y2KVar
+	switch fn {
+	case Y2KListAppend:
+		list.items = append(list.items, m.GetVar(id).clone(id))
+	case Y2KListGet:
+		result = list.items[index].clone(id)
+	case Y2KListSet:
+		list.items[index] = m.GetVar(id).clone(id)
+	case Y2KListLength:
+		result = newInt(id, big.NewInt(int64(len(list.items))))
+	case Y2KListRemove:
+		result = list.items[index].clone(id)
+		list.items = append(list.items[:index], list.items[index+1:]...)
+	case Y2KListSort:
+		sortItems(list.items)
+	}
+
+	list.numVal = float64(len(list.items))
+
+	// The result is written after the list is updated, since it replaces
+	// the list if it's stored in the list's own variable ID
+	if result != nil {
+		m.setVar(result)
+	}
+
+	return nil
+}
+
+// sortItems sorts the items of a list from lowest to highest, using the
+// same ordering as the CONDITION comparisons. Numbers are placed before
+// strings.
+func sortItems(items []*Y2KVar) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if (a.Type == Y2KString) != (b.Type == Y2KString) {
+			return b.Type == Y2KString
+		}
+
+		return compare(a, b) < 0
+	})
+}
@@ -0,0 +1,115 @@
+package interpreter_test
+
+import (
+	"github.com/benbusby/y2k/src/interpreter"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	runCases(t, []testCase{
+		{
+			name: "create and print",
+			asm: `
+var v1 = 1
+var v2 = "a"
+var v3 = [v1, v2]
+print v3`,
+			output: "[1, a]\n",
+		},
+		{
+			name: "items are copies",
+			asm: `
+var v1 = 1
+var v3 = [v1]
+v1 += 1
+print v3`,
+			output: "[1]\n",
+		},
+		{
+			name: "operations",
+			asm: `
+var v1 = 5
+var v2 = []
+list v2 append v1
+v1 = 7
+list v2 append v1
+list v2 length v3
+print v3
+list v2 get 1 v4
+print v4
+var v5 = 9
+list v2 set 0 v5
+list v2 remove 1 v6
+print v6
+print v2`,
+			output: "2\n7\n7\n[9]\n",
+		},
+		{
+			name: "variable index",
+			asm: `
+var v1 = 4
+var v2 = [v1, v1]
+list v2 append v2
+var v3 = 1
+list v2 get v3 v4
+print v4`,
+			output: "4\n",
+		},
+		{
+			name:   "sort",
+			asm:    "var v1 = args\nlist v1 sort\nprint v1",
+			args:   []string{"b", "3", "a", "1.5"},
+			output: "[1.5, 3, a, b]\n",
+		},
+		{
+			name:   "arguments",
+			asm:    "var v1 = args\nprint v1",
+			args:   []string{"5", "x"},
+			output: "[5, x]\n",
+		},
+		{
+			name: "loop over a list",
+			asm: `
+var v1 = args
+var v2 = 0
+var v3 = 0
+while v2 < v1 {
+  list v1 get v2 v4
+  v3 += v4
+  v2 += 1
+}
+print v3`,
+			args:   []string{"1", "2", "3"},
+			output: "6\n",
+		},
+		{
+			name:   "remove into the list's own ID",
+			raw:    "8150 0 3415101 0 9211",
+			args:   []string{"5", "6", "7"},
+			output: "5\n",
+		},
+		{
+			name:   "get into the list's own ID",
+			asm:    "var v1 = args\nlist v1 get 2 v1\nprint v1\nv1 += 1\nprint v1",
+			args:   []string{"5", "6", "7"},
+			output: "7\n8\n",
+		},
+		{
+			name:   "length into the list's own ID",
+			asm:    "var v1 = args\nlist v1 length v1\nprint v1",
+			args:   []string{"5", "6", "7"},
+			output: "3\n",
+		},
+		{
+			name: "index out of range",
+			asm:  "var v1 = args\nlist v1 get 3 v2",
+			args: []string{"1"},
+			err:  interpreter.ErrInvalidValue,
+		},
+		{
+			name: "not a list",
+			asm:  "var v1 = 3\nlist v1 length v2",
+			err:  interpreter.ErrInvalidValue,
+		},
+	})
+}
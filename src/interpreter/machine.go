@@ -0,0 +1,196 @@
+package interpreter
+
+import (
+	"github.com/benbusby/y2k/src/utils"
+)
+
+// machine runs a compiled program using a program counter and a stack of
+// the condition blocks that are currently running, rather than recursing for
+// each instruction or loop iteration. This allows programs of any length (and
+// loops that run forever) to be run without growing the Go stack.
+//
+// The embedded Y2K is a copy of the interpreter that Run was called on, so
+// the Debug value set by each instruction doesn't outlive the call to Run.
+type machine struct {
+	Y2K
+	program []Instruction
+	pc      int
+	blocks  []block
+	calls   int
+	halted  bool
+	status  int
+}
+
+// block is the body of a condition or subroutine that is currently being
+// run. The body of a condition starts at the instruction after the
+// condition's last CLAUSE command. Subroutines keep the index of the OpCall
+// that started them, so that the program can continue after it.
+type block struct {
+	header int
+	body   int
+	loop   bool
+	conds  []comparison
+	sub    bool
+	call   int
+}
+
+// test evaluates the block's condition. Comparisons joined by AND are
+// checked before those joined by OR, so "a OR b AND c" is read as
+// "a OR (b AND c)". Comparisons that can't change the result are skipped.
+func (b *block) test() bool {
+	result := false
+	group := true
+	for i := range b.conds {
+		cond := &b.conds[i]
+		if cond.or {
+			result = result || group
+			group = true
+		}
+
+		if group && !result {
+			group = cond.test()
+		}
+	}
+
+	return result || group
+}
+
+// run runs the program until the last instruction is finished, a CONTINUE
+// command is run outside of a loop, or an EXIT command is run.
+func (m *machine) run() error {
+	for !m.done() {
+		if err := m.step(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exitErr returns an *ExitError if the program was stopped by an EXIT
+// command with a non-zero status code.
+func (m *machine) exitErr() error {
+	if m.status != 0 {
+		return &ExitError{Code: m.status}
+	}
+
+	return nil
+}
+
+// done returns true once there are no instructions left to run.
+func (m *machine) done() bool {
+	return m.halted || m.pc >= len(m.program)
+}
+
+// step runs the instruction at the program counter.
+func (m *machine) step() error {
+	ins := &m.program[m.pc]
+
+	m.Debug = ins.Debug
+	for _, msg := range ins.trace {
+		m.OutputMsg(msg)
+	}
+
+	switch ins.Op {
+	case OpCommand:
+		if ins.exec != nil {
+			if err := ins.exec(m, ins); err != nil {
+				return m.fail(err, ins.Command)
+			}
+		}
+		m.pc++
+	case OpCondition:
+		if err := m.parseCondition(ins); err != nil {
+			return m.fail(err, ins.Command)
+		}
+	case OpEnd:
+		m.endBlock(ins)
+	case OpElse:
+		// The if branch has finished, so the else branch is skipped
+		m.pc = ins.Jump
+	case OpContinue:
+		if ins.Jump < 0 {
+			// CONTINUE outside of a loop ends the program
+			m.halted = true
+			return nil
+		}
+		m.continueLoop(ins.Jump)
+	case OpBreak:
+		m.breakLoop(ins.Jump)
+	case OpDefine:
+		// Subroutines are only run when they're called
+		m.pc = ins.Jump + 1
+	case OpCall:
+		if err := m.callSub(ins); err != nil {
+			return m.fail(err, ins.Command)
+		}
+	case OpReturn:
+		m.returnSub()
+	case OpSkip, OpClause:
+		m.pc++
+	case OpError:
+		return m.fail(ins.Err, ins.Command)
+	}
+
+	return nil
+}
+
+// endBlock is called when the end of a block is reached. Loops go back to
+// the start of the block's body if their condition is still true, otherwise the
+// block is removed and the program continues after the block. Reaching the
+// end of a subroutine returns from it.
+func (m *machine) endBlock(ins *Instruction) {
+	top := &m.blocks[len(m.blocks)-1]
+	if top.sub {
+		m.returnSub()
+		return
+	}
+
+	if top.loop && top.test() {
+		m.DebugMsg(utils.DebugDivider)
+		m.pc = top.body
+		return
+	}
+
+	m.blocks = m.blocks[:len(m.blocks)-1]
+	m.pc++
+}
+
+// continueLoop skips the rest of the loop started by the instruction at the
+// given index, so that its condition is checked again. Any blocks within the
+// loop are exited.
+func (m *machine) continueLoop(header int) {
+	for m.blocks[len(m.blocks)-1].header != header {
+		m.blocks = m.blocks[:len(m.blocks)-1]
+	}
+
+	m.pc = m.program[header].Jump
+}
+
+// breakLoop exits the loop started by the instruction at the given index,
+// along with any blocks within the loop, and continues after the end of the
+// loop without checking its condition.
+func (m *machine) breakLoop(header int) {
+	for m.blocks[len(m.blocks)-1].header != header {
+		m.blocks = m.blocks[:len(m.blocks)-1]
+	}
+
+	m.blocks = m.blocks[:len(m.blocks)-1]
+	m.pc = m.program[header].Jump + 1
+}
+
+// fail adds the command that was being run, and the conditions and
+// subroutine calls it was nested in, to an error.
+func (m *machine) fail(err error, command Y2KCommand) error {
+	err = withCommand(err, command)
+	for i := len(m.blocks) - 1; i >= 0; i-- {
+		if m.blocks[i].sub {
+			err = m.withTrace(err, CALL, m.program[m.blocks[i].call].Offset)
+			continue
+		}
+
+		err = m.withTrace(err, CONDITION, m.program[m.blocks[i].header].Offset)
+	}
+
+	return err
+}
@@ -1,52 +1,130 @@
 package interpreter
 
 import (
+	"fmt"
 	"github.com/benbusby/y2k/src/utils"
 	"math"
-	"reflect"
+	"math/big"
 	"strings"
 )
 
-type Y2KMod struct {
-	VarID    uint8
-	ModFn    uint8
-	ArgIsVar bool
-	ModSize  uint8
-	value    string
-}
+// Y2KModFn is an enum to indicate which function a MODIFY command performs.
+type Y2KModFn uint8
+
+const (
+	Y2KModAdd       Y2KModFn = 1
+	Y2KModSubtract  Y2KModFn = 2
+	Y2KModMultiply  Y2KModFn = 3
+	Y2KModDivide    Y2KModFn = 4
+	Y2KModPow       Y2KModFn = 5
+	Y2KModMod       Y2KModFn = 6
+	Y2KModIntDivide Y2KModFn = 7
+	Y2KModRemainder Y2KModFn = 8
+	Y2KModSet       Y2KModFn = 9
+	Y2KModAbs       Y2KModFn = 10
+	Y2KModFloor     Y2KModFn = 11
+	Y2KModCeil      Y2KModFn = 12
+	Y2KModRound     Y2KModFn = 13
+	Y2KModMin       Y2KModFn = 14
+	Y2KModMax       Y2KModFn = 15
+)
+
+// MaxIntBits is the largest size in bits of an integer that exponentiation
+// can produce, which is a little over 300,000 decimal digits.
+const MaxIntBits = 1 << 20
+
+// Fields of a MODIFY command
+const (
+	modVarID = iota
+	modFn
+	modArgIsVar
+	modSize
+)
 
 // modMap holds an int->function mapping to match timestamp input
-// to the appropriate function to perform on the specified variable.
-var modMap = map[uint8]func(*Y2KVar, string, float64){
-	1: AddToVar,
-	2: SubtractFromVar,
-	3: MultiplyVar,
-	4: DivideVar,
-	5: PowVar,
-	9: SetVar,
+// to the appropriate function to perform on the specified variable. The
+// argument is either another variable, or a literal value (see literalVar).
+var modMap = map[Y2KModFn]func(*Y2KVar, *Y2KVar) error{
+	Y2KModAdd:       AddToVar,
+	Y2KModSubtract:  SubtractFromVar,
+	Y2KModMultiply:  MultiplyVar,
+	Y2KModDivide:    DivideVar,
+	Y2KModPow:       PowVar,
+	Y2KModMod:       ModVar,
+	Y2KModIntDivide: IntDivideVar,
+	Y2KModRemainder: RemainderVar,
+	Y2KModSet:       SetVar,
+	Y2KModAbs:       AbsVar,
+	Y2KModFloor:     FloorVar,
+	Y2KModCeil:      CeilVar,
+	Y2KModRound:     RoundVar,
+	Y2KModMin:       MinVar,
+	Y2KModMax:       MaxVar,
+}
+
+// textModFns are the MODIFY functions that read the argument as text when
+// they're used with a string variable. The others read it as a number.
+var textModFns = map[Y2KModFn]bool{
+	Y2KModAdd:    true,
+	Y2KModDivide: true,
+	Y2KModSet:    true,
+	Y2KModMin:    true,
+	Y2KModMax:    true,
+}
+
+// bothInts checks if a variable and an argument are both integers, in which
+// case functions use their exact integer values.
+func bothInts(y2kVar *Y2KVar, arg *Y2KVar) bool {
+	return y2kVar.intVal != nil && arg.intVal != nil
 }
 
 // AddToVar directly modifies a variable by adding a second value to either its
 // numVal or strVal property (depending on variable data type).
-func AddToVar(y2kVar *Y2KVar, strVal string, numVal float64) {
+func AddToVar(y2kVar *Y2KVar, arg *Y2KVar) error {
 	if y2kVar.Type == Y2KString {
-		y2kVar.strVal += strVal
-		return
+		y2kVar.strVal += arg.strVal
+		return nil
+	} else if bothInts(y2kVar, arg) {
+		y2kVar.setInt(new(big.Int).Add(y2kVar.intVal, arg.intVal))
+		return nil
 	}
 
-	y2kVar.numVal += numVal
+	return y2kVar.setNumber(y2kVar.numVal + arg.numVal)
+}
+
+// count reads the argument of a function that removes or repeats the
+// characters of a string, which must be a whole number that isn't negative.
+func count(arg *Y2KVar) (int, bool) {
+	val := arg.numVal
+	if math.IsNaN(val) || math.IsInf(val, 0) || val < 0 || val > math.MaxInt32 || val != math.Trunc(val) {
+		return 0, false
+	}
+
+	return int(val), true
 }
 
 // SubtractFromVar modifies a variable by subtracting from the variable's value.
 // For strings, this results in a substring from 0:length-N. For all other
 // variable types, this is regular subtraction.
-func SubtractFromVar(y2kVar *Y2KVar, _ string, numVal float64) {
+func SubtractFromVar(y2kVar *Y2KVar, arg *Y2KVar) error {
 	if y2kVar.Type == Y2KString {
-		y2kVar.strVal = y2kVar.strVal[0 : len(y2kVar.strVal)-int(numVal)]
-		return
+		n, ok := count(arg)
+		if !ok || n > len(y2kVar.strVal) {
+			return fmt.Errorf(
+				"%w: can't remove %s characters from %q",
+				ErrInvalidValue,
+				utils.FloatToString(arg.numVal),
+				y2kVar.strVal)
+		}
+
+		y2kVar.strVal = y2kVar.strVal[0 : len(y2kVar.strVal)-n]
+		return nil
+	} else if bothInts(y2kVar, arg) {
+		y2kVar.setInt(new(big.Int).Sub(y2kVar.intVal, arg.intVal))
+		return nil
 	}
 
-	y2kVar.numVal -= numVal
+	return y2kVar.setNumber(y2kVar.numVal - arg.numVal)
 }
 
 // MultiplyVar directly modifies a variable by multiplying the value by a
@@ -54,99 +132,306 @@ func SubtractFromVar(y2kVar *Y2KVar, _ string, numVal float64) {
 // times. For all other variable types, this is regular multiplication. Note
 // that in this case, val is always treated as a number, even for string
 // variables.
-func MultiplyVar(y2kVar *Y2KVar, _ string, numVal float64) {
+func MultiplyVar(y2kVar *Y2KVar, arg *Y2KVar) error {
 	if y2kVar.Type == Y2KString {
-		y2kVar.strVal = strings.Repeat(y2kVar.strVal, int(numVal))
-		return
+		n, ok := count(arg)
+		if !ok {
+			return fmt.Errorf(
+				"%w: can't repeat a string %s times",
+				ErrInvalidValue,
+				utils.FloatToString(arg.numVal))
+		}
+
+		y2kVar.strVal = strings.Repeat(y2kVar.strVal, n)
+		return nil
+	} else if bothInts(y2kVar, arg) {
+		y2kVar.setInt(new(big.Int).Mul(y2kVar.intVal, arg.intVal))
+		return nil
 	}
 
-	y2kVar.numVal *= numVal
+	return y2kVar.setNumber(y2kVar.numVal * arg.numVal)
 }
 
 // DivideVar modifies a variable by dividing the value by a number (if the
 // variable is numeric) or a string (if the variable is a string). For strings,
 // this results in a string with all instances of the specified string removed.
-// For all other variable types, this is regular division.
+// For all other variable types, this is regular division, except that the
+// fraction is dropped for integers (so 7 / 2 is 3, and -7 / 2 is -3).
+// Dividing a number by 0 is an error.
 // value Example: "hello world!" / "o" -> "hell wrld!"
-func DivideVar(y2kVar *Y2KVar, strVal string, numVal float64) {
+func DivideVar(y2kVar *Y2KVar, arg *Y2KVar) error {
 	if y2kVar.Type == Y2KString {
-		y2kVar.strVal = strings.ReplaceAll(y2kVar.strVal, strVal, "")
-		return
+		y2kVar.strVal = strings.ReplaceAll(y2kVar.strVal, arg.strVal, "")
+		return nil
+	} else if err := divisor(arg); err != nil {
+		return err
+	} else if bothInts(y2kVar, arg) {
+		y2kVar.setInt(new(big.Int).Quo(y2kVar.intVal, arg.intVal))
+		return nil
 	}
 
-	y2kVar.numVal /= numVal
+	return y2kVar.setNumber(y2kVar.numVal / arg.numVal)
 }
 
 // PowVar returns the result of exponentiation with a variable's numeric
 // value as a base, and numVal input as the exponent.
 // This only applies to numeric variables -- string variables are ignored.
-func PowVar(y2kVar *Y2KVar, _ string, numVal float64) {
+func PowVar(y2kVar *Y2KVar, arg *Y2KVar) error {
+	if y2kVar.Type == Y2KString {
+		return nil
+	} else if bothInts(y2kVar, arg) && arg.intVal.Sign() >= 0 {
+		if err := powSize(y2kVar.intVal, arg.intVal); err != nil {
+			return err
+		}
+
+		y2kVar.setInt(new(big.Int).Exp(y2kVar.intVal, arg.intVal, nil))
+		return nil
+	}
+
+	return y2kVar.setNumber(math.Pow(y2kVar.numVal, arg.numVal))
+}
+
+// powSize returns an error if raising an integer to a power would need more
+// than MaxIntBits bits. A base of -1, 0 or 1 never grows, and any other base
+// adds at least BitLen()-1 bits for each time it's multiplied.
+func powSize(base *big.Int, exp *big.Int) error {
+	if base.CmpAbs(big.NewInt(1)) <= 0 {
+		return nil
+	} else if exp.Cmp(big.NewInt(MaxIntBits)) <= 0 &&
+		int64(base.BitLen()-1)*exp.Int64() <= MaxIntBits {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: exponentiation result is larger than %d bits",
+		ErrInvalidValue,
+		MaxIntBits)
+}
+
+// numericOnly returns an error for functions that can't be used with string
+// variables.
+func numericOnly(y2kVar *Y2KVar, name string) error {
 	if y2kVar.Type == Y2KString {
-		return
+		return fmt.Errorf("%w: %s can't be used with strings", ErrInvalidValue, name)
+	}
+
+	return nil
+}
+
+// divisor returns an error for functions that would divide by 0.
+func divisor(arg *Y2KVar) error {
+	if arg.numVal == 0 {
+		return fmt.Errorf("%w: division by zero", ErrInvalidValue)
+	}
+
+	return nil
+}
+
+// ModVar sets a variable to the modulo of its value and a number. The result
+// has the same sign as the number, so -7 % 3 is 2.
+func ModVar(y2kVar *Y2KVar, arg *Y2KVar) error {
+	if err := numericOnly(y2kVar, "modulo"); err != nil {
+		return err
+	} else if err := divisor(arg); err != nil {
+		return err
+	}
+
+	if bothInts(y2kVar, arg) {
+		mod := new(big.Int).Rem(y2kVar.intVal, arg.intVal)
+		if mod.Sign() != 0 && mod.Sign() != arg.intVal.Sign() {
+			mod.Add(mod, arg.intVal)
+		}
+
+		y2kVar.setInt(mod)
+		return nil
+	}
+
+	mod := math.Mod(y2kVar.numVal, arg.numVal)
+	if mod != 0 && (mod < 0) != (arg.numVal < 0) {
+		mod += arg.numVal
+	}
+
+	return y2kVar.setNumber(mod)
+}
+
+// IntDivideVar divides a variable by a number, rounding the result down to
+// a whole number (so -7 // 2 is -4).
+func IntDivideVar(y2kVar *Y2KVar, arg *Y2KVar) error {
+	if err := numericOnly(y2kVar, "integer division"); err != nil {
+		return err
+	} else if err := divisor(arg); err != nil {
+		return err
+	}
+
+	if bothInts(y2kVar, arg) {
+		quo, rem := new(big.Int).QuoRem(y2kVar.intVal, arg.intVal, new(big.Int))
+		if rem.Sign() != 0 && rem.Sign() != arg.intVal.Sign() {
+			quo.Sub(quo, big.NewInt(1))
+		}
+
+		y2kVar.setInt(quo)
+		return nil
+	}
+
+	return y2kVar.setNumber(math.Floor(y2kVar.numVal / arg.numVal))
+}
+
+// RemainderVar sets a variable to the remainder of dividing its value by a
+// number. Unlike ModVar, the result has the same sign as the variable, so
+// -7 rem 3 is -1.
+func RemainderVar(y2kVar *Y2KVar, arg *Y2KVar) error {
+	if err := numericOnly(y2kVar, "remainder"); err != nil {
+		return err
+	} else if err := divisor(arg); err != nil {
+		return err
+	}
+
+	if bothInts(y2kVar, arg) {
+		y2kVar.setInt(new(big.Int).Rem(y2kVar.intVal, arg.intVal))
+		return nil
+	}
+
+	return y2kVar.setNumber(math.Mod(y2kVar.numVal, arg.numVal))
+}
+
+// AbsVar sets a variable to its absolute value. The argument is ignored.
+func AbsVar(y2kVar *Y2KVar, _ *Y2KVar) error {
+	if err := numericOnly(y2kVar, "abs"); err != nil {
+		return err
+	} else if y2kVar.intVal != nil {
+		y2kVar.setInt(new(big.Int).Abs(y2kVar.intVal))
+		return nil
+	}
+
+	y2kVar.numVal = math.Abs(y2kVar.numVal)
+	return nil
+}
+
+// roundTo rounds a variable to a number of decimal places using a rounding
+// function, such as math.Floor. Integers are already rounded, so they're
+// left as they are.
+func roundTo(y2kVar *Y2KVar, fn func(float64) float64, places float64) error {
+	if y2kVar.intVal != nil {
+		return nil
+	}
+
+	scale := math.Pow(10, math.Trunc(places))
+	return y2kVar.setNumber(fn(y2kVar.numVal*scale) / scale)
+}
+
+// FloorVar rounds a variable down, keeping the number of decimal places
+// given as the argument (or none, if the argument is 0).
+func FloorVar(y2kVar *Y2KVar, arg *Y2KVar) error {
+	if err := numericOnly(y2kVar, "floor"); err != nil {
+		return err
+	}
+
+	return roundTo(y2kVar, math.Floor, arg.numVal)
+}
+
+// CeilVar rounds a variable up, keeping the number of decimal places given
+// as the argument (or none, if the argument is 0).
+func CeilVar(y2kVar *Y2KVar, arg *Y2KVar) error {
+	if err := numericOnly(y2kVar, "ceil"); err != nil {
+		return err
+	}
+
+	return roundTo(y2kVar, math.Ceil, arg.numVal)
+}
+
+// RoundVar rounds a variable to the nearest value with the number of
+// decimal places given as the argument (or none, if the argument is 0).
+// Halves are rounded away from zero.
+func RoundVar(y2kVar *Y2KVar, arg *Y2KVar) error {
+	if err := numericOnly(y2kVar, "round"); err != nil {
+		return err
 	}
 
-	y2kVar.numVal = math.Pow(y2kVar.numVal, numVal)
+	return roundTo(y2kVar, math.Round, arg.numVal)
+}
+
+// MinVar sets a variable to the lower of its value and the argument. Strings
+// are compared alphabetically.
+func MinVar(y2kVar *Y2KVar, arg *Y2KVar) error {
+	if compare(y2kVar, arg) > 0 {
+		return SetVar(y2kVar, arg)
+	}
+
+	return nil
+}
+
+// MaxVar sets a variable to the higher of its value and the argument.
+// Strings are compared alphabetically.
+func MaxVar(y2kVar *Y2KVar, arg *Y2KVar) error {
+	if compare(y2kVar, arg) < 0 {
+		return SetVar(y2kVar, arg)
+	}
+
+	return nil
 }
 
 // SetVar overwrites a variable's value with the given input. Note that you
 // cannot overwrite a string variable with a numeric value. You would want
-// to create a new variable (command 8) with the new data type in that case.
-func SetVar(y2kVar *Y2KVar, strVal string, numVal float64) {
+// to convert the variable first (see parseConvert), or create a new variable
+// (command 8) with the new data type in that case.
+// Integers are set to the whole number part of non-integer values.
+func SetVar(y2kVar *Y2KVar, arg *Y2KVar) error {
 	if y2kVar.Type == Y2KString {
-		y2kVar.strVal = strVal
-		return
+		y2kVar.strVal = arg.strVal
+		return nil
+	} else if bothInts(y2kVar, arg) {
+		y2kVar.setInt(new(big.Int).Set(arg.intVal))
+		return nil
 	}
 
-	y2kVar.numVal = numVal
+	return y2kVar.setNumber(arg.numVal)
 }
 
-// ParseModify recursively builds a set of values to modify an existing
-// variable. The order of values are:
+// parseModify builds a set of values to modify an existing variable. The
+// order of values are:
 //
 //	<target variable ID> -> <function ID> -> <mod size> -> <mod value>
 //
 // Once the mod size has been reached, we can pass the mod value to the desired
-// function and return the timestamp back to the original caller.
-func (y2k Y2K) ParseModify(timestamp string, val reflect.Value) string {
-	varMod := val.Interface().(Y2KMod)
-
-	input := timestamp[:y2k.Digits]
-	y2k.DebugMsg("ParseModify: [%s]%s",
-		input,
-		timestamp[y2k.Digits:],
-	)
-
-	varMod.value += input
-
-	if len(varMod.value) >= int(varMod.ModSize) {
-		// Although we have the desired size of the modification, we don't
-		// know how the modification value needs to be interpreted. By
-		// converting the mod value to a slice of strings, we can pass off
-		// final interpretation of the value to the actual function that is
-		// performing the modification. For example, adding to a string
-		// should interpret inputs as a string ("h" + 9 == "hi"), but
-		// multiplying a string should interpret the input as a number.
-		// ("h" * 9 == "hhhhhhhhh").
-		targetVar := GetVar(varMod.VarID)
-		varMod.value = varMod.value[:varMod.ModSize]
-
-		// Retrieve the possible str and num values of the provided values
-		splitValue := utils.SplitStrByN(varMod.value, y2k.Digits)
-		strVal := utils.StrArrToPrintable(splitValue)
-		numVal := utils.StrArrToFloat(splitValue)
-
-		// If the user specified that the argument is a variable, use the
-		// provided input as a variable ID lookup and overwrite the values
-		// determined earlier
-		if varMod.ArgIsVar {
-			argVar := GetVar(uint8(utils.StrArrToInt(splitValue)))
-			strVal, numVal = argVar.GetValues()
-		}
+// function.
+func (m *machine) parseModify(ins *Instruction) error {
+	value := ins.Value[:ins.Fields[modSize]]
+	fn := Y2KModFn(ins.Fields[modFn])
+	modFn := modMap[fn]
 
-		modMap[varMod.ModFn](targetVar, strVal, numVal)
+	// Although we have the desired size of the modification, we don't
+	// know how the modification value needs to be interpreted. By
+	// converting the mod value to a variable with both a string and a
+	// numeric value, we can pass off final interpretation of the value to
+	// the actual function that is performing the modification. For
+	// example, adding to a string should interpret inputs as a string
+	// ("h" + 9 == "hi"), but multiplying a string should interpret the
+	// input as a number ("h" * 9 == "hhhhhhhhh").
+	targetVar := m.GetVar(uint8(ins.Fields[modVarID]))
+	if targetVar.Type == Y2KList {
+		return m.errorAt(ins.Offset, "", fmt.Errorf(
+			"%w: lists can only be changed with the LIST command",
+			ErrInvalidValue))
+	}
+
+	// If the user specified that the argument is a variable, use the
+	// provided input as a variable ID lookup instead
+	var arg *Y2KVar
+	var err error
+	if ins.Fields[modArgIsVar] != 0 {
+		arg, err = m.varByID(value, ins.valueOffset)
+	} else {
+		arg, err = m.literal(value, ins.valueOffset, ins, targetVar.Type == Y2KString && textModFns[fn])
+	}
+
+	if err != nil {
+		return err
+	}
 
-		return timestamp
+	err = modFn(targetVar, arg)
+	if err != nil {
+		return m.errorAt(ins.valueOffset, "value", err)
 	}
 
-	return y2k.ParseModify(timestamp[y2k.Digits:], reflect.ValueOf(varMod))
+	return nil
 }
@@ -0,0 +1,146 @@
+package interpreter_test
+
+import (
+	"github.com/benbusby/y2k/src/interpreter"
+	"testing"
+)
+
+func TestModify(t *testing.T) {
+	runCases(t, []testCase{
+		{
+			name: "arithmetic",
+			asm: `
+var v1 = 10
+v1 -= 4
+v1 *= 3
+v1 **= 2
+print v1
+var v2 = 7.5
+v2 /= 2
+print v2`,
+			output: "324\n3.75\n",
+		},
+		{
+			name: "modulo, integer division and remainder",
+			asm: `
+var v1 = 0
+v1 -= 7
+v1 %= 3
+print v1
+var v2 = 0
+v2 -= 7
+v2 //= 2
+print v2
+var v3 = 0
+v3 -= 7
+v3 rem= 3
+print v3`,
+			output: "2\n-4\n-1\n",
+		},
+		{
+			name: "rounding",
+			asm: `
+var v1 = 0.5
+v1 -= 3
+v1 abs
+print v1
+var v2 = 2.25
+v2 floor
+print v2
+var v3 = 2.25
+v3 ceil
+print v3
+var v4 = 2.256
+v4 round 2
+print v4`,
+			output: "2.5\n2\n3\n2.26\n",
+		},
+		{
+			name: "min and max",
+			asm: `
+var v1 = 5
+v1 min= 3
+print v1
+v1 max= 8
+print v1`,
+			output: "3\n8\n",
+		},
+		{
+			name: "strings",
+			asm: `
+var v1 = "ab"
+v1 += "cd"
+print v1
+v1 -= 1
+print v1
+v1 *= 2
+print v1`,
+			output: "abcd\nabc\nabcabc\n",
+		},
+		{
+			name:   "arbitrary precision integers",
+			asm:    "var v1 = 2\nv1 **= 100\nv1 += 1\nprint v1",
+			output: "1267650600228229401496703205377\n",
+		},
+		{
+			name: "exponentiation result is too large",
+			raw:  "812210 7150899999999 9211",
+			err:  interpreter.ErrInvalidValue,
+		},
+		{
+			name:   "exponentiation of 1 and -1 doesn't grow",
+			asm:    "var v1 = 0\nv1 -= 1\nv1 **= 99999999\nvar v2 = 1\nv2 **= 99999999\nprint v1, v2",
+			output: "-1 1\n",
+		},
+		{
+			name:   "exponentiation up to the size limit",
+			asm:    "var v1 = 2\nv1 **= 1048576\nvar v2 = 0\nif v1 > v2 {\n  print \"ok\"\n}",
+			output: "ok\n",
+		},
+		{
+			name: "exponentiation past the size limit",
+			asm:  "var v1 = 2\nv1 **= 1048577",
+			err:  interpreter.ErrInvalidValue,
+		},
+		{
+			name:   "integer division truncates",
+			asm:    "var v1 = 0\nv1 -= 7\nv1 /= 2\nprint v1",
+			output: "-3\n",
+		},
+		{
+			name: "integer division by zero",
+			asm:  "var v1 = 7\nv1 /= 0",
+			err:  interpreter.ErrInvalidValue,
+		},
+		{
+			name: "float division by zero",
+			asm:  "var v1 = 7.5\nv1 /= 0",
+			err:  interpreter.ErrInvalidValue,
+		},
+		{
+			name: "division by zero before a string is changed",
+			raw:  "811212 724010 713112 9211",
+			err:  interpreter.ErrInvalidValue,
+		},
+		{
+			name: "remove infinite characters",
+			asm:  "var v1 = 10.5\nv1 **= 400\nvar v2 = \"ab\"\nv2 -= v1",
+			err:  interpreter.ErrInvalidValue,
+		},
+		{
+			name: "repeat a string NaN times",
+			asm:  "var v1 = 10.5\nv1 **= 400\nvar v2 = v1\nv1 -= v2\nvar v3 = \"ab\"\nv3 *= v1",
+			err:  interpreter.ErrInvalidValue,
+		},
+		{
+			name: "remove a fraction of a character",
+			asm:  "var v1 = 1.5\nvar v2 = \"ab\"\nv2 -= v1",
+			err:  interpreter.ErrInvalidValue,
+		},
+		{
+			name: "remove too many characters",
+			asm:  "var v1 = \"abc\"\nv1 -= 5",
+			err:  interpreter.ErrInvalidValue,
+		},
+	})
+}
@@ -2,53 +2,156 @@ package interpreter
 
 import (
 	"github.com/benbusby/y2k/src/utils"
-	"reflect"
+	"strconv"
+	"strings"
 )
 
 // Y2KPrintType is an enum to indicate to the interpreter what should be printed.
 type Y2KPrintType uint8
 
 const (
-	Y2KPrintString Y2KPrintType = 1
-	Y2KPrintVar    Y2KPrintType = 2
+	Y2KPrintString       Y2KPrintType = 1
+	Y2KPrintVar          Y2KPrintType = 2
+	Y2KPrintStringInline Y2KPrintType = 3
+	Y2KPrintVarInline    Y2KPrintType = 4
+	Y2KPrintVars         Y2KPrintType = 5
+	Y2KPrintVarsInline   Y2KPrintType = 6
+	Y2KPrintNumber       Y2KPrintType = 7
+	Y2KPrintNumberInline Y2KPrintType = 8
 )
 
-type Y2KPrint struct {
-	Type  Y2KPrintType
-	Size  int
-	value string
+// Fields of a PRINT command
+const (
+	printType = iota
+	printSize
+)
+
+// Chunks of the value of a PRINT command for a formatted number
+const (
+	printNumVarID = iota
+	printNumWidth
+	printNumZeroPad
+)
+
+// printChunks returns the number of chunks in the value of a PRINT command.
+// Strings use one chunk per character, and variable IDs use Size chunks. A
+// list of variables has a chunk for the separator, and one chunk for each
+// variable. Formatted numbers use one chunk each for the variable ID, the
+// width and the zero padding flag.
+func printChunks(fields []int, _ int) int {
+	switch Y2KPrintType(fields[printType]) {
+	case Y2KPrintVars, Y2KPrintVarsInline:
+		return fields[printSize] + 1
+	case Y2KPrintNumber, Y2KPrintNumberInline:
+		return printNumZeroPad + 1
+	}
+
+	return fields[printSize]
+}
+
+// parsePrint prints the value of a print command (either a string or a
+// variable ID). The "inline" types don't add a newline to the end of what
+// they print, so that the next PRINT continues on the same line. Output is
+// written to stderr instead if it was turned on by a META command.
+func (m *machine) parsePrint(ins *Instruction) error {
+	// If we're printing a variable, the value will be an integer
+	// variable ID to print. Otherwise, we need to split the string
+	// into N-sized chunks (dependent on interpreter parsing window
+	// size) and print each character that matches each digit.
+	var output string
+	printType := Y2KPrintType(ins.Fields[printType])
+	switch printType {
+	case Y2KPrintString, Y2KPrintStringInline:
+		// At least one chunk is always read, so an empty string has
+		// one chunk that isn't printed
+		splitValues := utils.SplitStrByN(ins.Value, ins.Digits)
+		text, err := m.decodeText(splitValues[:ins.Fields[printSize]], ins.valueOffset, ins)
+		if err != nil {
+			return err
+		}
+
+		output = text
+	case Y2KPrintVar, Y2KPrintVarInline:
+		printVar, err := m.varByID(ins.Value, ins.valueOffset)
+		if err != nil {
+			return err
+		}
+
+		output = printVar.GetValue()
+	case Y2KPrintVars, Y2KPrintVarsInline:
+		chunks := utils.SplitStrByN(ins.Value, ins.Digits)
+		separator, err := m.decodeText(chunks[:1], ins.valueOffset, ins)
+		if err != nil {
+			return err
+		}
+
+		values := make([]string, ins.Fields[printSize])
+		for i, chunk := range chunks[1 : len(values)+1] {
+			printVar, err := m.varByID(chunk, ins.valueOffset+(i+1)*ins.Digits)
+			if err != nil {
+				return err
+			}
+
+			values[i] = printVar.GetValue()
+		}
+
+		output = strings.Join(values, separator)
+	case Y2KPrintNumber, Y2KPrintNumberInline:
+		chunks := utils.SplitStrByN(ins.Value, ins.Digits)
+		printVar, err := m.varByID(chunks[printNumVarID], ins.valueOffset+printNumVarID*ins.Digits)
+		if err != nil {
+			return err
+		}
+
+		output = formatNumber(
+			printVar,
+			ins.Fields[printSize],
+			utils.StrToInt(chunks[printNumWidth]),
+			utils.StrToInt(chunks[printNumZeroPad]) != 0)
+	}
+
+	switch printType {
+	case Y2KPrintString, Y2KPrintVar, Y2KPrintVars, Y2KPrintNumber:
+		output += "\n"
+	}
+
+	if ins.Stderr {
+		m.ErrOutput(output)
+	} else {
+		m.Output(output)
+	}
+
+	return nil
 }
 
-func (y2k Y2K) ParsePrint(timestamp string, val reflect.Value) string {
-	y2kPrint := val.Interface().(Y2KPrint)
-
-	input := timestamp[:y2k.Digits]
-	y2k.DebugMsg("ParsePrint: [%s]%s",
-		input,
-		timestamp[y2k.Digits:],
-	)
-
-	y2kPrint.value += input
-
-	if len(y2kPrint.value) >= y2kPrint.Size*y2k.Digits {
-		// If we're printing a variable, the value will be an integer
-		// variable ID to print. Otherwise, we need to split the string
-		// into N-sized chunks (dependent on interpreter parsing window
-		// size) and print each character that matches each digit.
-		switch y2kPrint.Type {
-		case Y2KPrintString:
-			splitValues := utils.SplitStrByN(y2kPrint.value, y2k.Digits)
-			strValue := utils.StrArrToPrintable(splitValues)
-			y2k.OutputMsg(strValue)
-			break
-		case Y2KPrintVar:
-			printVar := GetVar(uint8(utils.StrToInt(y2kPrint.value)))
-			y2k.OutputMsg(printVar.GetValue())
-			break
+// formatNumber formats the value of a variable with a fixed number of
+// decimal places, and pads it on the left with spaces (or zeros, after the
+// sign) until it's at least width characters long. Strings and lists are
+// only padded.
+func formatNumber(y2kVar *Y2KVar, decimals int, width int, zeroPad bool) string {
+	output := y2kVar.GetValue()
+	switch {
+	case y2kVar.Type == Y2KString || y2kVar.Type == Y2KList:
+		zeroPad = false
+	case y2kVar.intVal != nil:
+		if decimals > 0 {
+			output += "." + strings.Repeat("0", decimals)
 		}
+	default:
+		output = strconv.FormatFloat(y2kVar.numVal, 'f', decimals, 64)
+	}
+
+	padding := width - len([]rune(output))
+	if padding <= 0 {
+		return output
+	} else if !zeroPad {
+		return strings.Repeat(" ", padding) + output
+	}
 
-		return timestamp
+	sign := ""
+	if strings.HasPrefix(output, "-") {
+		sign, output = "-", output[1:]
 	}
 
-	return y2k.ParsePrint(timestamp[y2k.Digits:], reflect.ValueOf(y2kPrint))
+	return sign + strings.Repeat("0", padding) + output
 }
@@ -0,0 +1,61 @@
+package interpreter_test
+
+import (
+	"testing"
+)
+
+func TestPrint(t *testing.T) {
+	runCases(t, []testCase{
+		{
+			name: "write",
+			asm: `
+write "a"
+var v1 = 1
+write v1
+print "b"`,
+			output: "a1b\n",
+		},
+		{
+			name: "joined variables",
+			asm: `
+var v1 = 1
+var v2 = "x"
+print v1, v2
+print v1, v2 sep ","`,
+			output: "1 x\n1,x\n",
+		},
+		{
+			name: "formatted numbers",
+			asm: `
+var v1 = 3.14159
+print v1 decimals 2
+print v1 decimals 1 width 6
+var v2 = 5
+print v2 width 3 zeros`,
+			output: "3.14\n   3.1\n005\n",
+		},
+		{
+			name:   "empty string",
+			asm:    "print \"\"",
+			output: "\n",
+		},
+		{
+			name: "unicode",
+			asm: `
+charset unicode
+print "é\n✓"`,
+			output: "é\n✓\n",
+		},
+		{
+			name: "charset only applies to its block",
+			asm: `
+var v1 = 1
+if v1 == 1 {
+  charset unicode
+  write "é"
+}
+print "a"`,
+			output: "éa\n",
+		},
+	})
+}
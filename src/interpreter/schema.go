@@ -0,0 +1,611 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Field is a single N-sized chunk that follows a command ID, which is read
+// as a number.
+type Field struct {
+	Name string
+	Help string
+
+	// Max is the largest value the field can hold, or 0 if there's no
+	// limit.
+	Max int
+
+	// Values maps each value the field accepts to a description of what
+	// it does. If Values is nil, any number is accepted.
+	Values map[int]string
+}
+
+// Schema describes how a command is decoded and run. The command ID is
+// followed by one chunk for each of the command's fields, and then by the
+// number of value chunks returned by Chunks (if set). At least one value
+// chunk is always read, even if Chunks returns 0.
+type Schema struct {
+	Command Y2KCommand
+	Name    string
+	Help    string
+	Fields  []Field
+
+	// Value describes the value that follows the fields.
+	Value  string
+	Chunks func(fields []int, digits int) int
+
+	op   Op
+	exec func(*machine, *Instruction) error
+}
+
+// maxVarID is the largest variable ID that can be used.
+const maxVarID = 255
+
+// schemas holds every command, in the order that they're listed by Help.
+var schemas []*Schema
+
+// schemaMap holds the commands that can be read directly from a timestamp,
+// and extendedMap holds the extended commands by their extended ID. The
+// extended commands are kept separate, so that they can only be reached
+// through the EXTEND command (otherwise the chunk "101" would run INPUT when
+// parsing 3 digits at a time, instead of being ignored).
+var (
+	schemaMap   map[Y2KCommand]*Schema
+	extendedMap map[int]*Schema
+)
+
+// LookupCommand returns the schema for a command ID, or false if the
+// command doesn't exist. Extended commands are looked up by their full
+// command ID (i.e. INPUT).
+func LookupCommand(command Y2KCommand) (*Schema, bool) {
+	if id, ok := command.ExtendedID(); ok {
+		schema, ok := extendedMap[id]
+		return schema, ok
+	}
+
+	schema, ok := schemaMap[command]
+	return schema, ok
+}
+
+// Field returns the index of a field in the schema, or -1 if the schema
+// doesn't have a field with that name.
+func (schema *Schema) Field(name string) int {
+	for i, field := range schema.Fields {
+		if field.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Usage returns the format of the command, i.e. "9 PRINT <Type> <Size>
+// <value>".
+func (schema *Schema) Usage() string {
+	usage := fmt.Sprintf("%d %s", schema.Command, schema.Name)
+	if id, ok := schema.Command.ExtendedID(); ok {
+		usage = fmt.Sprintf("%d %d %s", EXTEND, id, schema.Name)
+	}
+	for _, field := range schema.Fields {
+		usage += fmt.Sprintf(" <%s>", field.Name)
+	}
+
+	if schema.Chunks != nil {
+		usage += " <value>"
+	}
+
+	return usage
+}
+
+// check returns an error if a value can't be used for the field.
+func (field Field) check(val int) error {
+	if field.Max > 0 && val > field.Max {
+		return fmt.Errorf("%w: %d is out of range", ErrInvalidValue, val)
+	}
+
+	if _, ok := field.Values[val]; field.Values != nil && !ok {
+		return fmt.Errorf("%w: unknown %s %d",
+			ErrInvalidValue,
+			strings.ToLower(field.Name),
+			val)
+	}
+
+	return nil
+}
+
+// Help returns a description of every command and its fields, generated
+// from the command schemas.
+func Help() string {
+	var help strings.Builder
+
+	for _, schema := range schemas {
+		help.WriteString(schema.Usage() + "\n")
+		help.WriteString("    " + schema.Help + "\n")
+
+		for _, field := range schema.Fields {
+			help.WriteString(fmt.Sprintf("    %-12s %s\n", field.Name, field.Help))
+
+			values := make([]int, 0, len(field.Values))
+			for val := range field.Values {
+				values = append(values, val)
+			}
+			sort.Ints(values)
+
+			for _, val := range values {
+				help.WriteString(fmt.Sprintf("    %-12s   %d --> %s\n", "", val, field.Values[val]))
+			}
+		}
+
+		if schema.Chunks != nil {
+			help.WriteString(fmt.Sprintf("    %-12s %s\n", "value", schema.Value))
+		}
+
+		help.WriteString("\n")
+	}
+
+	return strings.TrimSuffix(help.String(), "\n")
+}
+
+// ExtendedID returns the ID that follows the EXTEND command for an extended
+// command, or false if the command isn't an extended command.
+func (command Y2KCommand) ExtendedID() (int, bool) {
+	if command < extendedBase {
+		return 0, false
+	}
+
+	return int(command - extendedBase), true
+}
+
+// compFnValues describes each comparison of the CONDITION and CLAUSE
+// commands.
+var compFnValues = map[int]string{
+	int(Y2KCompEqual):          "==",
+	int(Y2KCompLess):           "< (strings are compared alphabetically)",
+	int(Y2KCompGreater):        ">",
+	int(Y2KCompDivisible):      "Is evenly divisible by (strings: contains)",
+	int(Y2KCompNotEqual):       "!=",
+	int(Y2KCompLessOrEqual):    "<=",
+	int(Y2KCompGreaterOrEqual): ">=",
+	int(Y2KCompNotDivisible):   "Is not evenly divisible by (strings: doesn't contain)",
+	int(Y2KCompStartsWith):     "Starts with",
+}
+
+// chunksFor returns the number of N-sized chunks needed to hold a value of
+// the given number of digits.
+func chunksFor(size int, digits int) int {
+	return (size + digits - 1) / digits
+}
+
+func init() {
+	schemas = []*Schema{
+		{
+			Command: PRINT,
+			Name:    "PRINT",
+			Help:    "Print variable or string",
+			Fields: []Field{
+				{
+					Name: "Type",
+					Help: "What should be printed",
+					Values: map[int]string{
+						int(Y2KPrintString):       "String",
+						int(Y2KPrintVar):          "Variable",
+						int(Y2KPrintStringInline): "String (without a newline)",
+						int(Y2KPrintVarInline):    "Variable (without a newline)",
+						int(Y2KPrintVars):         "Variables, with a separator between them",
+						int(Y2KPrintVarsInline):   "Variables, with a separator between them (without a newline)",
+						int(Y2KPrintNumber):       "Formatted number",
+						int(Y2KPrintNumberInline): "Formatted number (without a newline)",
+					},
+				},
+				{
+					Name: "Size",
+					Help: "# of characters in the string, # of digits in the variable ID, # of variables, or # of decimal places of a formatted number",
+				},
+			},
+			Value:  "Character codes of the string, or the ID of the variable. Variables start with the character code of the separator, followed by the ID of each variable. Formatted numbers are the ID of the variable, the minimum width, and 1 to pad with zeros (0 to pad with spaces)",
+			Chunks: printChunks,
+			exec:   (*machine).parsePrint,
+		},
+		{
+			Command: CREATE,
+			Name:    "CREATE",
+			Help:    "Create a new variable",
+			Fields: []Field{
+				{
+					Name: "ID",
+					Help: "ID of the new variable",
+					Max:  maxVarID,
+				},
+				{
+					Name: "Type",
+					Help: "Data type of the new variable",
+					Values: map[int]string{
+						int(Y2KString):  "String",
+						int(Y2KInt):     "Integer",
+						int(Y2KFloat):   "Float (the first digit of the value is the decimal position)",
+						int(Y2KList):    "List (the value is the IDs of the variables to copy into the list)",
+						int(Y2KArgs):    "List of the command line arguments (no value)",
+						int(Y2KVarCopy): "Copy (the value is the ID of the variable to copy)",
+					},
+				},
+				{
+					Name: "Size",
+					Help: "# of characters (strings), items (lists) or digits (all other types) in the value",
+					Max:  255,
+				},
+			},
+			Value:  "Character codes (strings), variable IDs (lists) or digits (all other types) of the new variable",
+			Chunks: variableChunks,
+			exec:   (*machine).parseVariable,
+		},
+		{
+			Command: MODIFY,
+			Name:    "MODIFY",
+			Help:    "Modify an existing variable",
+			Fields: []Field{
+				{
+					Name: "VarID",
+					Help: "ID of the variable to modify",
+					Max:  maxVarID,
+				},
+				{
+					Name: "ModFn",
+					Help: "Function to modify the variable with",
+					Values: map[int]string{
+						int(Y2KModAdd):       "+=",
+						int(Y2KModSubtract):  "-=",
+						int(Y2KModMultiply):  "*=",
+						int(Y2KModDivide):    "/=",
+						int(Y2KModPow):       "**= (exponentiation)",
+						int(Y2KModMod):       "%= (modulo, with the sign of the argument)",
+						int(Y2KModIntDivide): "//= (integer division, rounded down)",
+						int(Y2KModRemainder): "Remainder (with the sign of the variable)",
+						int(Y2KModSet):       "=",
+						int(Y2KModAbs):       "Absolute value (the argument is ignored)",
+						int(Y2KModFloor):     "Floor (the argument is the # of decimal places to keep)",
+						int(Y2KModCeil):      "Ceiling (the argument is the # of decimal places to keep)",
+						int(Y2KModRound):     "Round (the argument is the # of decimal places to keep)",
+						int(Y2KModMin):       "Minimum of the variable and the argument",
+						int(Y2KModMax):       "Maximum of the variable and the argument",
+					},
+				},
+				{
+					Name: "ArgIsVar",
+					Help: "1 if the value is the ID of a variable to use as the argument",
+				},
+				{
+					Name: "ModSize",
+					Help: "# of digits in the value",
+					Max:  255,
+				},
+			},
+			Value: "Argument for the function",
+			Chunks: func(fields []int, digits int) int {
+				return chunksFor(fields[modSize], digits)
+			},
+			exec: (*machine).parseModify,
+		},
+		{
+			Command: CONDITION,
+			Name:    "CONDITION",
+			Help:    "Create a condition, which ends at \"2000\" (if) or \"1999\" (while). If blocks can have an else branch starting at \"2001\"",
+			Fields: []Field{
+				{
+					Name: "VarID",
+					Help: "ID of the variable to compare",
+					Max:  maxVarID,
+				},
+				{
+					Name:   "CompFn",
+					Help:   "Comparison to perform",
+					Values: compFnValues,
+				},
+				{
+					Name: "Flags",
+					Help: "Sum of: 1 for a while loop (otherwise an if statement), 2 if the value is the ID of a variable to compare against, 4 to negate the comparison",
+					Max:  CondFlagLoop | CondFlagIsVar | CondFlagNegate,
+				},
+				{
+					Name: "CompValSize",
+					Help: "# of digits in the value (or variable ID)",
+					Max:  255,
+				},
+			},
+			Value: "Value (or ID of the variable) to compare the variable against",
+			Chunks: func(fields []int, digits int) int {
+				return chunksFor(fields[condValSize], digits)
+			},
+			op: OpCondition,
+		},
+		{
+			Command: META,
+			Name:    "META",
+			Help:    "Modify interpreter state until the end of the current block",
+			Fields: []Field{
+				{
+					Name: "Flags",
+					Help: "Sum of: 1 to turn on debug mode, 2 to read character codes as Unicode code points (otherwise they're read from the table of printable characters), 4 to PRINT to stderr",
+					Max:  MetaFlagDebug | MetaFlagUnicode | MetaFlagStderr,
+				},
+				{
+					Name: "Digits",
+					Help: "# of digits parsed on each pass of the interpreter",
+				},
+			},
+		},
+		{
+			Command: CONTINUE,
+			Name:    "CONTINUE",
+			Help:    "Skip to the end of the innermost loop and check its condition again, or end the program (outside of a loop)",
+			op:      OpContinue,
+		},
+		{
+			Command: EXTEND,
+			Name:    "EXTEND",
+			Help:    "Run an extended command (listed below)",
+			Fields: []Field{
+				{
+					Name: "Command",
+					Help: "ID of the extended command",
+				},
+			},
+		},
+		{
+			Command: INPUT,
+			Name:    "INPUT",
+			Help:    "Read from stdin into a variable (an empty string once the input ends)",
+			Fields: []Field{
+				{
+					Name: "VarID",
+					Help: "ID of the variable to read into",
+					Max:  maxVarID,
+				},
+				{
+					Name: "Mode",
+					Help: "How much input to read",
+					Values: map[int]string{
+						int(Y2KInputLine):   "Line (the type is inferred like command line arguments)",
+						int(Y2KInputNumber): "Number (the next whitespace-separated word)",
+					},
+				},
+			},
+			exec: (*machine).parseInput,
+		},
+		{
+			Command: CLAUSE,
+			Name:    "CLAUSE",
+			Help:    "Add a comparison to the CONDITION (or CLAUSE) directly before it. AND is checked before OR",
+			Fields: []Field{
+				{
+					Name: "Logic",
+					Help: "How the comparison is combined with the ones before it",
+					Values: map[int]string{
+						ClauseAnd: "AND",
+						ClauseOr:  "OR",
+					},
+				},
+				{
+					Name: "VarID",
+					Help: "ID of the variable to compare",
+					Max:  maxVarID,
+				},
+				{
+					Name:   "CompFn",
+					Help:   "Comparison to perform (the same as CONDITION)",
+					Values: compFnValues,
+				},
+				{
+					Name: "Flags",
+					Help: "Sum of: 2 if the value is the ID of a variable to compare against, 4 to negate the comparison",
+					Max:  CondFlagIsVar | CondFlagNegate,
+				},
+				{
+					Name: "CompValSize",
+					Help: "# of digits in the value (or variable ID)",
+					Max:  255,
+				},
+			},
+			Value: "Value (or ID of the variable) to compare the variable against",
+			Chunks: func(fields []int, digits int) int {
+				return chunksFor(fields[clauseVarID+condValSize], digits)
+			},
+			op: OpClause,
+		},
+		{
+			Command: BREAK,
+			Name:    "BREAK",
+			Help:    "Exit the innermost loop, and continue after its \"1999\"",
+			op:      OpBreak,
+		},
+		{
+			Command: LIST,
+			Name:    "LIST",
+			Help:    "Change or read from a list variable",
+			Fields: []Field{
+				{
+					Name: "ListID",
+					Help: "ID of the list",
+					Max:  maxVarID,
+				},
+				{
+					Name: "ListFn",
+					Help: "Operation to perform",
+					Values: map[int]string{
+						int(Y2KListAppend): "Append a copy of the variable",
+						int(Y2KListGet):    "Copy the item at the index into the variable",
+						int(Y2KListSet):    "Replace the item at the index with a copy of the variable",
+						int(Y2KListLength): "Set the variable to the # of items",
+						int(Y2KListRemove): "Remove the item at the index, and move it into the variable",
+						int(Y2KListSort):   "Sort the items from lowest to highest (numbers before strings)",
+					},
+				},
+				{
+					Name: "VarID",
+					Help: "ID of the variable to use",
+					Max:  maxVarID,
+				},
+				{
+					Name: "IndexIsVar",
+					Help: "1 if the value is the ID of a variable holding the index",
+				},
+				{
+					Name: "IndexSize",
+					Help: "# of digits in the value",
+					Max:  255,
+				},
+			},
+			Value: "Index of the item, starting at 0 (only used to get, set or remove)",
+			Chunks: func(fields []int, digits int) int {
+				return chunksFor(fields[listIndexSize], digits)
+			},
+			exec: (*machine).parseList,
+		},
+		{
+			Command: STRING,
+			Name:    "STRING",
+			Help:    "Read from the text of a variable (numbers use their digits), and store the result in a variable",
+			Fields: []Field{
+				{
+					Name: "VarID",
+					Help: "ID of the variable to read",
+					Max:  maxVarID,
+				},
+				{
+					Name: "StrFn",
+					Help: "Operation to perform",
+					Values: map[int]string{
+						int(Y2KStrLength):   "Set the variable to the # of characters",
+						int(Y2KStrFrom):     "Copy the characters from the index to the end",
+						int(Y2KStrTo):       "Copy the characters before the index",
+						int(Y2KStrFind):     "Set the variable to the index where the argument first appears (-1 if it doesn't)",
+						int(Y2KStrCharCode): "Set the variable to the code of the character at the index (-1 if it has no code)",
+						int(Y2KStrReverse):  "Copy the characters in reverse order",
+						int(Y2KStrUpper):    "Copy the text in upper case",
+						int(Y2KStrLower):    "Copy the text in lower case",
+						int(Y2KStrSplit):    "Split the text at each appearance of the argument into a list (types are inferred like command line arguments)",
+						int(Y2KStrJoin):     "Join the items of a list into a string, with the argument between each item",
+					},
+				},
+				{
+					Name: "DestID",
+					Help: "ID of the variable to store the result in",
+					Max:  maxVarID,
+				},
+				{
+					Name: "ArgIsVar",
+					Help: "1 if the value is the ID of a variable to use as the argument",
+				},
+				{
+					Name: "ArgSize",
+					Help: "# of digits in the value",
+					Max:  255,
+				},
+			},
+			Value: "Index (starting at 0) or string argument of the operation",
+			Chunks: func(fields []int, digits int) int {
+				return chunksFor(fields[strArgSize], digits)
+			},
+			exec: (*machine).parseString,
+		},
+		{
+			Command: CONVERT,
+			Name:    "CONVERT",
+			Help:    "Convert the value of a variable to a different type, and store it in a variable",
+			Fields: []Field{
+				{
+					Name: "VarID",
+					Help: "ID of the variable to convert",
+					Max:  maxVarID,
+				},
+				{
+					Name: "ConvFn",
+					Help: "Conversion to perform",
+					Values: map[int]string{
+						int(Y2KConvString): "String (numbers use their digits)",
+						int(Y2KConvInt):    "Integer (strings are read as a number, and floats are truncated)",
+						int(Y2KConvFloat):  "Float (strings are read as a number)",
+						int(Y2KConvRound):  "Integer (rounded to the nearest whole number)",
+						int(Y2KConvChar):   "Character with the character code in the variable",
+						int(Y2KConvCode):   "Character code of the first character (-1 if it has no code)",
+					},
+				},
+				{
+					Name: "DestID",
+					Help: "ID of the variable to store the result in (the same ID converts the variable in place)",
+					Max:  maxVarID,
+				},
+			},
+			exec: (*machine).parseConvert,
+		},
+		{
+			Command: DEFINE,
+			Name:    "DEFINE",
+			Help:    "Define a subroutine, which ends at \"2000\". Subroutines are only run when they're called",
+			Fields: []Field{
+				{
+					Name: "SubID",
+					Help: "Number of the subroutine",
+				},
+			},
+			op: OpDefine,
+		},
+		{
+			Command: CALL,
+			Name:    "CALL",
+			Help:    "Run a subroutine (which can be defined before or after the call), and continue once it returns",
+			Fields: []Field{
+				{
+					Name: "SubID",
+					Help: "Number of the subroutine",
+				},
+			},
+			op: OpCall,
+		},
+		{
+			Command: RETURN,
+			Name:    "RETURN",
+			Help:    "Exit the subroutine that is running",
+			op:      OpReturn,
+		},
+		{
+			Command: EXIT,
+			Name:    "EXIT",
+			Help:    "End the program with a status code",
+			Fields: []Field{
+				{
+					Name: "CodeIsVar",
+					Help: "1 if the value is the ID of a variable holding the status code",
+				},
+				{
+					Name: "CodeSize",
+					Help: "# of digits in the value",
+					Max:  255,
+				},
+			},
+			Value: "Status code from 0 to 255 (0 if the value is empty)",
+			Chunks: func(fields []int, digits int) int {
+				return chunksFor(fields[exitCodeSize], digits)
+			},
+			exec: (*machine).parseExit,
+		},
+	}
+
+	schemaMap = map[Y2KCommand]*Schema{}
+	extendedMap = map[int]*Schema{}
+	for _, schema := range schemas {
+		if id, ok := schema.Command.ExtendedID(); ok {
+			extendedMap[id] = schema
+		} else {
+			schemaMap[schema.Command] = schema
+		}
+	}
+
+	// The IDs accepted by EXTEND are those of the extended commands above
+	extend := schemaMap[EXTEND].Fields[extendID]
+	extend.Values = map[int]string{}
+	for id, schema := range extendedMap {
+		extend.Values[id] = schema.Name
+	}
+	schemaMap[EXTEND].Fields[extendID] = extend
+}
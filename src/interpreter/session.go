@@ -0,0 +1,90 @@
+package interpreter
+
+import (
+	"sort"
+)
+
+// Session runs a compiled program one instruction at a time, so that the
+// program can be inspected (or stopped) in between instructions. It's used
+// by the "y2k debug" command.
+type Session struct {
+	m   *machine
+	err error
+}
+
+// NewSession creates a Session for a program that was decoded with Compile.
+// Nothing is run until Step is called.
+func (y2k Y2K) NewSession(program *Program) *Session {
+	return &Session{m: &machine{Y2K: y2k, program: program.Instructions}}
+}
+
+// Done returns true once the program has finished, or if an instruction
+// returned an error.
+func (s *Session) Done() bool {
+	return s.err != nil || s.m.done()
+}
+
+// Err returns the error that stopped the program, if any.
+func (s *Session) Err() error {
+	return s.err
+}
+
+// Next returns the instruction that will be run by the next call to Step,
+// or nil if the program is done.
+func (s *Session) Next() *Instruction {
+	if s.Done() {
+		return nil
+	}
+
+	return &s.m.program[s.m.pc]
+}
+
+// PC returns the index of the next instruction in the program.
+func (s *Session) PC() int {
+	return s.m.pc
+}
+
+// Depth returns the number of blocks that are currently running.
+func (s *Session) Depth() int {
+	return len(s.m.blocks)
+}
+
+// Step runs the next instruction. Once an instruction returns an error, the
+// session is done and the same error is returned for each following call.
+// An EXIT command with a non-zero status code returns an *ExitError.
+func (s *Session) Step() error {
+	if s.Done() {
+		return s.err
+	}
+
+	s.err = s.m.step()
+	if s.err == nil {
+		s.err = s.m.out.Flush()
+	}
+	if s.err == nil {
+		s.err = s.m.exitErr()
+	}
+
+	return s.err
+}
+
+// Var returns the variable with the given ID, or false if the variable
+// hasn't been created yet.
+func (s *Session) Var(id uint8) (*Y2KVar, bool) {
+	variable, ok := s.m.vars[id]
+	return variable, ok
+}
+
+// Vars returns all variables that have been created, ordered by ID.
+func (s *Session) Vars() []*Y2KVar {
+	vars := make([]*Y2KVar, 0, len(s.m.vars))
+	for _, variable := range s.m.vars {
+		vars = append(vars, variable)
+	}
+
+	sort.Slice(vars, func(i, j int) bool {
+		return vars[i].ID < vars[j].ID
+	})
+
+	return vars
+}
@@ -0,0 +1,178 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/benbusby/y2k/src/interpreter"
+	"strings"
+	"testing"
+)
+
+// newSession compiles a raw program, and creates a Session for it that
+// prints to out.
+func newSession(t *testing.T, raw string, out *bytes.Buffer) *interpreter.Session {
+	t.Helper()
+
+	y2k := interpreter.New(1, false, strings.NewReader(""), out)
+	program, err := y2k.Compile(strings.Join(strings.Fields(raw), ""))
+	if err != nil {
+		t.Fatalf("can't compile program: %v", err)
+	}
+
+	return y2k.NewSession(program)
+}
+
+func TestSessionStep(t *testing.T) {
+	var out bytes.Buffer
+
+	// Count to 2 in a loop, then print "d"
+	session := newSession(t, `
+		8 1 2 1 0
+		6 1 2 1 1 2
+		  7 1 1 0 1 1
+		  9 2 1 1
+		1999
+		9 1 1 4`, &out)
+
+	if _, ok := session.Var(1); ok {
+		t.Errorf("v1 exists before the program has started")
+	}
+
+	// Each step is the index of the instruction that should be run next,
+	// the block depth before running it, and the output so far.
+	steps := []struct {
+		pc     int
+		depth  int
+		output string
+	}{
+		{0, 0, ""},
+		{1, 0, ""},
+		{2, 1, ""},
+		{3, 1, ""},
+		{4, 1, "1\n"},
+		{2, 1, "1\n"},
+		{3, 1, "1\n"},
+		{4, 1, "1\n2\n"},
+		{5, 0, "1\n2\n"},
+	}
+
+	for i, step := range steps {
+		if session.Done() {
+			t.Fatalf("step %d: session is done", i)
+		}
+
+		if session.PC() != step.pc {
+			t.Errorf("step %d: PC() = %d, want %d", i, session.PC(), step.pc)
+		}
+
+		if session.Depth() != step.depth {
+			t.Errorf("step %d: Depth() = %d, want %d", i, session.Depth(), step.depth)
+		}
+
+		if session.Next() == nil {
+			t.Fatalf("step %d: Next() = nil", i)
+		}
+
+		if out.String() != step.output {
+			t.Errorf("step %d: output = %q, want %q", i, out.String(), step.output)
+		}
+
+		if err := session.Step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+
+	if !session.Done() || session.Next() != nil || session.Err() != nil {
+		t.Errorf("session isn't done: Next() = %v, Err() = %v",
+			session.Next(),
+			session.Err())
+	}
+
+	if out.String() != "1\n2\nd\n" {
+		t.Errorf("output = %q, want %q", out.String(), "1\n2\nd\n")
+	}
+
+	// Stepping a finished session does nothing
+	if err := session.Step(); err != nil {
+		t.Errorf("Step() after the end = %v", err)
+	}
+}
+
+func TestSessionVars(t *testing.T) {
+	var out bytes.Buffer
+	session := newSession(t, "8 2 1 2 1 2 8 1 2 1 5", &out)
+
+	if vars := session.Vars(); len(vars) != 0 {
+		t.Errorf("Vars() = %v before any steps", vars)
+	}
+
+	for !session.Done() {
+		if err := session.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	vars := session.Vars()
+	if len(vars) != 2 || vars[0].ID != 1 || vars[1].ID != 2 {
+		t.Fatalf("Vars() = %v, want v1 and v2", vars)
+	}
+
+	if v1, ok := session.Var(1); !ok || v1.GetValue() != "5" {
+		t.Errorf("Var(1) = %v, %t", v1, ok)
+	}
+
+	if v2, ok := session.Var(2); !ok || v2.GetValue() != "ab" {
+		t.Errorf("Var(2) = %v, %t", v2, ok)
+	}
+}
+
+func TestSessionErrors(t *testing.T) {
+	t.Run("exit status", func(t *testing.T) {
+		var out bytes.Buffer
+		session := newSession(t, "9 1 1 1 3 0 0 1 3 9 1 1 2", &out)
+
+		var err error
+		for !session.Done() {
+			err = session.Step()
+		}
+
+		var exitErr *interpreter.ExitError
+		if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+			t.Errorf("error = %v, want exit status 3", err)
+		}
+
+		if session.Err() != err || session.Step() != err {
+			t.Errorf("Err() = %v, Step() = %v, want %v", session.Err(), session.Step(), err)
+		}
+
+		if out.String() != "a\n" {
+			t.Errorf("output = %q, want %q", out.String(), "a\n")
+		}
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		var out bytes.Buffer
+		session := newSession(t, "8 1 2 1 7 7 1 4 0 1 0 9 1 1 1", &out)
+
+		if err := session.Step(); err != nil {
+			t.Fatal(err)
+		}
+
+		err := session.Step()
+		if !errors.Is(err, interpreter.ErrInvalidValue) {
+			t.Fatalf("error = %v, want %v", err, interpreter.ErrInvalidValue)
+		}
+
+		if !session.Done() || session.Next() != nil || session.PC() != 1 {
+			t.Errorf("session continued after an error: PC() = %d", session.PC())
+		}
+
+		if session.Step() != err {
+			t.Errorf("Step() after an error = %v, want %v", session.Step(), err)
+		}
+
+		if out.Len() != 0 {
+			t.Errorf("output = %q, want none", out.String())
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package interpreter
+
+import (
+	"fmt"
+	"github.com/benbusby/y2k/src/utils"
+	"math/big"
+	"strings"
+)
+
+// Y2KStrFn is an enum to indicate which operation a STRING command performs.
+type Y2KStrFn uint8
+
+const (
+	Y2KStrLength   Y2KStrFn = 1
+	Y2KStrFrom     Y2KStrFn = 2
+	Y2KStrTo       Y2KStrFn = 3
+	Y2KStrFind     Y2KStrFn = 4
+	Y2KStrCharCode Y2KStrFn = 5
+	Y2KStrReverse  Y2KStrFn = 6
+	Y2KStrUpper    Y2KStrFn = 7
+	Y2KStrLower    Y2KStrFn = 8
+	Y2KStrSplit    Y2KStrFn = 9
+	Y2KStrJoin     Y2KStrFn = 10
+)
+
+// Fields of a STRING command
+const (
+	strVarID = iota
+	strFn
+	strDestID
+	strArgIsVar
+	strArgSize
+)
+
+// newString creates a string variable.
+func newString(id uint8, val string) *Y2KVar {
+	return &Y2KVar{ID: id, Type: Y2KString, strVal: val}
+}
+
+// parseString reads from the text of a variable, and stores the result in
+// the destination variable (which can be the same variable). Numbers are
+// read using their digits, so the length of 1234 is 4. The value of the
+// command is the argument of the operation (or the ID of a variable holding
+// the argument), which is an index for the from, to and char code
+// operations, and a string for the others.
+func (m *machine) parseString(ins *Instruction) error {
+	value := ins.Value[:ins.Fields[strArgSize]]
+	fn := Y2KStrFn(ins.Fields[strFn])
+	isText := fn == Y2KStrFind || fn == Y2KStrSplit || fn == Y2KStrJoin
+
+	var arg *Y2KVar
+	var err error
+	if ins.Fields[strArgIsVar] != 0 {
+		arg, err = m.varByID(value, ins.valueOffset)
+	} else {
+		arg, err = m.literal(value, ins.valueOffset, ins, isText)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	text := arg.strVal
+	if ins.Fields[strArgIsVar] != 0 {
+		text = arg.GetValue()
+	}
+
+	source := m.GetVar(uint8(ins.Fields[strVarID]))
+	chars := []rune(source.GetValue())
+	index := int(arg.numVal)
+
+	limit := len(chars)
+	if fn == Y2KStrCharCode {
+		limit--
+	}
+
+	if (fn == Y2KStrFrom || fn == Y2KStrTo || fn == Y2KStrCharCode) &&
+		(index < 0 || index > limit) {
+		return m.errorAt(ins.valueOffset, "value", fmt.Errorf(
+			"%w: index %d is out of range for %q",
+			ErrInvalidValue,
+			index,
+			string(chars)))
+	}
+
+	id := uint8(ins.Fields[strDestID])
+	switch fn {
+	case Y2KStrLength:
+		m.setVar(newInt(id, big.NewInt(int64(len(chars)))))
+	case Y2KStrFrom:
+		m.setVar(newString(id, string(chars[index:])))
+	case Y2KStrTo:
+		m.setVar(newString(id, string(chars[:index])))
+	case Y2KStrFind:
+		m.setVar(newInt(id, big.NewInt(int64(findRunes(chars, text)))))
+	case Y2KStrCharCode:
+		code := utils.CharToCode(chars[index], ins.Unicode)
+		m.setVar(newInt(id, big.NewInt(int64(code))))
+	case Y2KStrReverse:
+		for i, j := 0, len(chars)-1; i < j; i, j = i+1, j-1 {
+			chars[i], chars[j] = chars[j], chars[i]
+		}
+
+		m.setVar(newString(id, string(chars)))
+	case Y2KStrUpper:
+		m.setVar(newString(id, strings.ToUpper(string(chars))))
+	case Y2KStrLower:
+		m.setVar(newString(id, strings.ToLower(string(chars))))
+	case Y2KStrSplit:
+		// Each part uses the ID of the list, since there can be more parts
+		// than variable IDs
+		newList := &Y2KVar{ID: id, Type: Y2KList, items: []*Y2KVar{}}
+		for _, part := range strings.Split(string(chars), text) {
+			newList.items = append(newList.items, inferVar(id, part))
+		}
+
+		newList.numVal = float64(len(newList.items))
+		m.setVar(newList)
+	case Y2KStrJoin:
+		if source.Type != Y2KList {
+			return m.errorAt(ins.Offset, "", fmt.Errorf(
+				"%w: v%d is not a list",
+				ErrInvalidValue,
+				source.ID))
+		}
+
+		values := make([]string, len(source.items))
+		for i, item := range source.items {
+			values[i] = item.GetValue()
+		}
+
+		m.setVar(newString(id, strings.Join(values, text)))
+	}
+
+	return nil
+}
+
+// findRunes returns the index of the first character of substr in chars, or
+// -1 if chars doesn't contain substr. The index counts characters rather
+// than bytes.
+func findRunes(chars []rune, substr string) int {
+	index := strings.Index(string(chars), substr)
+	if index < 0 {
+		return -1
+	}
+
+	return len([]rune(string(chars)[:index]))
+}
@@ -0,0 +1,31 @@
+package interpreter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Item IDs aren't visible to programs, so this test is in the interpreter
+// package to check them directly.
+func TestSplitItemIDs(t *testing.T) {
+	y2k := New(1, false, strings.NewReader(""), &bytes.Buffer{})
+	y2k.FromCLIArg(strings.Repeat("a,", 299) + "a")
+	y2k.FromCLIArg(",")
+
+	// Split v9 by the text in v8, and store the parts in v2
+	if err := y2k.Parse("38992118"); err != nil {
+		t.Fatal(err)
+	}
+
+	list := y2k.GetVar(2)
+	if len(list.items) != 300 {
+		t.Fatalf("split into %d parts, want 300", len(list.items))
+	}
+
+	for i, item := range list.items {
+		if item.ID != list.ID {
+			t.Fatalf("item %d has ID %d, want %d", i, item.ID, list.ID)
+		}
+	}
+}
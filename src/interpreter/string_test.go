@@ -0,0 +1,92 @@
+package interpreter_test
+
+import (
+	"github.com/benbusby/y2k/src/interpreter"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	runCases(t, []testCase{
+		{
+			name: "length and substrings",
+			asm: `
+var v1 = "hello"
+string v1 length v2
+print v2
+string v1 from 3 v2
+print v2
+string v1 to 2 v2
+print v2`,
+			output: "5\nlo\nhe\n",
+		},
+		{
+			name: "find",
+			asm: `
+var v1 = "hello"
+string v1 find "ll" v2
+print v2
+string v1 find "x" v2
+print v2`,
+			output: "2\n-1\n",
+		},
+		{
+			name: "case and reverse",
+			asm: `
+var v1 = "Hello"
+string v1 upper v2
+print v2
+string v1 lower v2
+print v2
+string v1 reverse v1
+print v1`,
+			output: "HELLO\nhello\nolleH\n",
+		},
+		{
+			name: "character code",
+			asm: `
+var v1 = "abc"
+string v1 charcode 1 v2
+print v2`,
+			output: "2\n",
+		},
+		{
+			name: "split and join",
+			asm: `
+var v1 = "1,b,3"
+string v1 split "," v2
+list v2 get 0 v3
+v3 += 1
+print v3
+string v2 join "-" v4
+print v4`,
+			output: "2\n1-b-3\n",
+		},
+		{
+			name: "numbers use their digits",
+			asm: `
+var v1 = 1234
+string v1 length v2
+print v2`,
+			output: "4\n",
+		},
+		{
+			name: "variable argument",
+			asm: `
+var v1 = "hello"
+var v2 = 1
+string v1 from v2 v3
+print v3`,
+			output: "ello\n",
+		},
+		{
+			name: "index out of range",
+			asm:  "var v1 = \"abc\"\nstring v1 from 4 v2",
+			err:  interpreter.ErrInvalidValue,
+		},
+		{
+			name: "join a string",
+			asm:  "var v1 = \"abc\"\nstring v1 join \",\" v2",
+			err:  interpreter.ErrInvalidValue,
+		},
+	})
+}
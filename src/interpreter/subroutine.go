@@ -0,0 +1,51 @@
+package interpreter
+
+import (
+	"fmt"
+	"github.com/benbusby/y2k/src/utils"
+)
+
+// MaxCallDepth is the largest number of subroutine calls that can be running
+// at once, which limits how deeply a subroutine can recurse.
+const MaxCallDepth = 1000
+
+// subID is the only field of the DEFINE and CALL commands, which holds the
+// number of the subroutine to define or call.
+const subID = 0
+
+// callSub starts running the body of a subroutine. The subroutine's block
+// is removed by returnSub, either by a RETURN command or by reaching the
+// end of the subroutine.
+func (m *machine) callSub(ins *Instruction) error {
+	if ins.Jump < 0 {
+		return m.errorAt(ins.Offset, "", fmt.Errorf(
+			"%w: subroutine %d isn't defined",
+			ErrInvalidValue,
+			ins.Fields[subID]))
+	} else if m.calls >= MaxCallDepth {
+		return m.errorAt(ins.Offset, "", fmt.Errorf(
+			"%w: more than %d subroutine calls are running",
+			ErrCallDepth,
+			MaxCallDepth))
+	}
+
+	m.DebugMsg(utils.DebugDivider)
+	m.blocks = append(m.blocks, block{header: ins.Jump, sub: true, call: m.pc})
+	m.calls++
+	m.pc = ins.Jump + 1
+
+	return nil
+}
+
+// returnSub exits the innermost subroutine, along with any blocks within
+// it, and continues after the CALL command that started it.
+func (m *machine) returnSub() {
+	for !m.blocks[len(m.blocks)-1].sub {
+		m.blocks = m.blocks[:len(m.blocks)-1]
+	}
+
+	top := m.blocks[len(m.blocks)-1]
+	m.blocks = m.blocks[:len(m.blocks)-1]
+	m.calls--
+	m.pc = top.call + 1
+}
@@ -0,0 +1,79 @@
+package interpreter_test
+
+import (
+	"github.com/benbusby/y2k/src/interpreter"
+	"testing"
+)
+
+func TestSubroutine(t *testing.T) {
+	runCases(t, []testCase{
+		{
+			name: "call before and after the definition",
+			asm: `
+call 1
+sub 1 {
+  print "in"
+}
+call 1
+print "out"`,
+			output: "in\nin\nout\n",
+		},
+		{
+			name: "return",
+			asm: `
+sub 1 {
+  print "a"
+  return
+  print "b"
+}
+call 1
+print "c"`,
+			output: "a\nc\n",
+		},
+		{
+			name: "return from inside of a loop",
+			asm: `
+sub 1 {
+  while v1 < 10 {
+    v1 += 1
+    if v1 == 2 {
+      return
+    }
+  }
+}
+call 1
+print v1`,
+			output: "2\n",
+		},
+		{
+			name: "recursion",
+			asm: `
+var v1 = 5
+var v2 = 1
+sub 1 {
+  if v1 > 1 {
+    v2 *= v1
+    v1 -= 1
+    call 1
+  }
+}
+call 1
+print v2`,
+			output: "120\n",
+		},
+		{
+			name: "undefined subroutine",
+			asm:  "call 4",
+			err:  interpreter.ErrInvalidValue,
+		},
+		{
+			name: "call depth",
+			asm: `
+sub 1 {
+  call 1
+}
+call 1`,
+			err: interpreter.ErrCallDepth,
+		},
+	})
+}
@@ -1,15 +1,15 @@
 package interpreter
 
 import (
+	"fmt"
 	"github.com/benbusby/y2k/src/utils"
-	"reflect"
+	"math"
+	"math/big"
 	"strconv"
 	"strings"
 	"unicode"
 )
 
-var VarMap = map[uint8]*Y2KVar{}
-
 // Y2KVarType is an enum to indicate how the interpreter should treat a Y2KVar.
 type Y2KVarType uint8
 
@@ -17,55 +17,280 @@ const (
 	Y2KString  Y2KVarType = 1
 	Y2KInt     Y2KVarType = 2
 	Y2KFloat   Y2KVarType = 3
+	Y2KList    Y2KVarType = 4
+	Y2KArgs    Y2KVarType = 5
 	Y2KVarCopy Y2KVarType = 9
 )
 
+// Fields of a CREATE command
+const (
+	varID = iota
+	varType
+	varSize
+)
+
+var varTypeNames = map[Y2KVarType]string{
+	Y2KString:  "string",
+	Y2KInt:     "int",
+	Y2KFloat:   "float",
+	Y2KList:    "list",
+	Y2KArgs:    "args",
+	Y2KVarCopy: "copy",
+}
+
+func (varType Y2KVarType) String() string {
+	if name, ok := varTypeNames[varType]; ok {
+		return name
+	}
+
+	// Variables that are used before they're created don't have a type,
+	// and are treated as numeric
+	return "unset"
+}
+
 // Y2KVar is a struct for all variables created by Y2K programs. These contain
 // both numeric and string values as well as a data type. When creating numeric
 // variables, the strVal property is used to construct a numeric value while
 // parsing, until the variable's Size is reached.
+//
+// Integers keep their exact value in intVal, which can be any size, and
+// numVal holds the closest float to it for use with floats.
+//
+// Lists keep their items in items, and use the number of items as their
+// numeric value, so that they can be compared against an index in a loop.
 type Y2KVar struct {
 	ID     uint8
 	Type   Y2KVarType
 	Size   uint8
 	strVal string
 	numVal float64
+	intVal *big.Int
+	items  []*Y2KVar
 }
 
 // GetValue returns the appropriate value for a particular variable. If it's a
 // numeric variable, it returns the numeric value, otherwise it returns the
-// string value.
+// string value. Lists return the values of their items, i.e. "[1, 2, 3]".
 func (y2kVar *Y2KVar) GetValue() string {
-	if y2kVar.Type == Y2KString {
+	switch y2kVar.Type {
+	case Y2KString:
 		return y2kVar.strVal
+	case Y2KList:
+		values := make([]string, len(y2kVar.items))
+		for i, item := range y2kVar.items {
+			values[i] = item.GetValue()
+		}
+
+		return "[" + strings.Join(values, ", ") + "]"
+	}
+
+	if y2kVar.intVal != nil {
+		return y2kVar.intVal.String()
 	}
 
 	return utils.FloatToString(y2kVar.numVal)
 }
 
+// Len returns the size of a variable's current value, which is the # of
+// characters of a string, the # of items of a list, or the # of digits of a
+// number. Unlike Size, which is set when the variable is created, it follows
+// the value as it changes.
+func (y2kVar *Y2KVar) Len() int {
+	switch y2kVar.Type {
+	case Y2KString:
+		return len([]rune(y2kVar.strVal))
+	case Y2KList:
+		return len(y2kVar.items)
+	}
+
+	digits := 0
+	for _, c := range y2kVar.GetValue() {
+		if unicode.IsDigit(c) {
+			digits++
+		}
+	}
+
+	return digits
+}
+
+// setInt sets the value of an integer variable.
+func (y2kVar *Y2KVar) setInt(val *big.Int) {
+	y2kVar.intVal = val
+	y2kVar.numVal, _ = new(big.Float).SetInt(val).Float64()
+}
+
+// setNumber sets the numeric value of a variable. Integers are set to the
+// whole number part of the value, so that they stay integral.
+func (y2kVar *Y2KVar) setNumber(val float64) error {
+	if y2kVar.Type != Y2KInt {
+		y2kVar.numVal = val
+		return nil
+	}
+
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		return fmt.Errorf(
+			"%w: %s can't be stored in an integer",
+			ErrInvalidValue,
+			utils.FloatToString(val))
+	}
+
+	intVal, _ := big.NewFloat(val).Int(nil)
+	y2kVar.setInt(intVal)
+	return nil
+}
+
+// newInt creates an integer variable.
+func newInt(id uint8, val *big.Int) *Y2KVar {
+	newVar := &Y2KVar{ID: id, Type: Y2KInt}
+	newVar.setInt(val)
+	return newVar
+}
+
+// literal creates a variable for a literal value that's used as an
+// argument, such as the value of a MODIFY command, which starts at the given
+// offset of the timestamp. Literals have an integer value (reading the
+// digits as a number), and a string value (reading them as character codes)
+// if text is true. The digits of a number don't have to be valid character
+// codes, so callers only ask for the text when they use it.
+func (m *machine) literal(value string, offset int, ins *Instruction, text bool) (*Y2KVar, error) {
+	intVal, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		intVal = new(big.Int)
+	}
+
+	literal := newInt(0, intVal)
+	if text {
+		var err error
+		literal.strVal, err = m.decodeText(utils.SplitStrByN(value, ins.Digits), offset, ins)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return literal, nil
+}
+
+// decodeText converts N-sized chunks of digits to characters, using the
+// character set of the instruction. The chunks start at the given offset of
+// the timestamp, and an error is returned for the first code that doesn't
+// have a character. Empty chunks, which are left over from splitting an
+// empty value, are skipped.
+func (m *machine) decodeText(chunks []string, offset int, ins *Instruction) (string, error) {
+	var text strings.Builder
+	for i, chunk := range chunks {
+		if len(chunk) == 0 {
+			continue
+		}
+
+		char, ok := utils.CodeToChar(utils.StrToInt(chunk), ins.Unicode)
+		if !ok {
+			return "", m.errorAt(offset+i*ins.Digits, "value", fmt.Errorf(
+				"%w: no character for code %s",
+				ErrInvalidValue,
+				chunk))
+		}
+
+		text.WriteRune(char)
+	}
+
+	return text.String(), nil
+}
+
+// clone returns a copy of a variable with a new ID. The integer value and
+// the items of lists are copied as well, so changing the copy doesn't change
+// the original.
+func (y2kVar *Y2KVar) clone(id uint8) *Y2KVar {
+	newVar := *y2kVar
+	newVar.ID = id
+	if y2kVar.intVal != nil {
+		newVar.intVal = new(big.Int).Set(y2kVar.intVal)
+	}
+
+	if y2kVar.items != nil {
+		newVar.items = make([]*Y2KVar, len(y2kVar.items))
+		for i, item := range y2kVar.items {
+			newVar.items[i] = item.clone(item.ID)
+		}
+	}
+
+	return &newVar
+}
+
 // GetValues returns both strVal and numVal of a variable.
 func (y2kVar *Y2KVar) GetValues() (string, float64) {
 	return y2kVar.strVal, y2kVar.numVal
 }
 
-// GetVar retrieves a variable from the existing ID->var map,
+// GetVar retrieves a variable from the interpreter's ID->var map,
 // or returns an empty version of the variable struct if the
 // request var id has not been set.
-func GetVar(id uint8) *Y2KVar {
-	if variable, ok := VarMap[id]; ok {
+func (y2k Y2K) GetVar(id uint8) *Y2KVar {
+	if variable, ok := y2k.vars[id]; ok {
 		return variable
 	}
 
 	// If the variable has not been set yet, insert it now.
-	VarMap[id] = &Y2KVar{}
-	return VarMap[id]
+	y2k.vars[id] = &Y2KVar{ID: id}
+	return y2k.vars[id]
+}
+
+// setVar stores the result of a command in a variable. Unlike CREATE (see
+// parseVariable), an existing variable with the same ID is overwritten in
+// place, so that conditions which compare against the variable see its new
+// value.
+func (y2k Y2K) setVar(newVar *Y2KVar) {
+	*y2k.GetVar(newVar.ID) = *newVar
+}
+
+// ParseVarID converts a string of digits to a variable ID. The second return
+// value is false if the digits don't fit in a variable ID.
+func ParseVarID(input string) (uint8, bool) {
+	id, err := strconv.ParseUint(input, 10, 8)
+	return uint8(id), err == nil
+}
+
+// varByID returns the variable with the ID written in a command's value,
+// which starts at the given offset of the timestamp. An error is returned
+// if the digits don't fit in a variable ID.
+func (m *machine) varByID(value string, offset int) (*Y2KVar, error) {
+	id, ok := ParseVarID(value)
+	if !ok {
+		return nil, m.errorAt(offset, "value", fmt.Errorf(
+			"%w: variable ID %s is out of range",
+			ErrInvalidValue,
+			value))
+	}
+
+	return m.GetVar(id), nil
 }
 
 // FromCLIArg takes a command line argument and turns it into a variable for the
 // programs to reference as needed. Variables added from the command line are
 // inserted into the map backwards from the map's max index (9 for 1-digit
 // parsing, 99 for 2-digit parsing, etc).
-func (y2k Y2K) FromCLIArg(input string) {
+//
+// Each argument is also kept in order, so that programs can create a list of
+// all of the arguments (see Y2KArgs).
+func (y2k *Y2K) FromCLIArg(input string) {
+	// Command line variables are added to the end of the map, which depends on
+	// the number of digits that are parsed at one time (a parsing size of 1
+	// should insert variables from 9->8->etc, a parsing size of 2 should insert
+	// from 99->98->etc.)
+	mapInd, _ := strconv.Atoi(strings.Repeat("9", y2k.Digits))
+	for y2k.vars[uint8(mapInd)] != nil {
+		mapInd -= 1
+	}
+
+	// Finalize and insert the new var into the previously determined index
+	y2k.vars[uint8(mapInd)] = inferVar(uint8(mapInd), input)
+	y2k.args = append(y2k.args, input)
+}
+
+// inferVar creates a variable from text that was given to the program (as a
+// command line argument or from stdin), which is numeric unless the text
+// contains letters or can't be parsed as a number. Whole numbers are
+// integers, and all other numbers are floats.
+func inferVar(id uint8, input string) *Y2KVar {
 	// Determine if the argument is a string or numeric.
 	// Assume the variable is numeric, unless a non-numeric other than '.' is
 	// found.
@@ -76,26 +301,45 @@ func (y2k Y2K) FromCLIArg(input string) {
 		}
 	}
 
-	// Command line variables are added to the end of the map, which depends on
-	// the number of digits that are parsed at one time (a parsing size of 1
-	// should insert variables from 9->8->etc, a parsing size of 2 should insert
-	// from 99->98->etc.)
-	mapInd, _ := strconv.Atoi(strings.Repeat("9", y2k.Digits))
-	for VarMap[uint8(mapInd)] != nil {
-		mapInd -= 1
+	// Arguments that look numeric but can't be parsed as a number (i.e.
+	// "1-2") are treated as strings, rather than being set to 0.
+	numVal, err := strconv.ParseFloat(input, 64)
+	if err != nil {
+		argType = Y2KString
 	}
 
-	// Finalize and insert the new var into the previously determined index
-	VarMap[uint8(mapInd)] = &Y2KVar{
-		ID:     uint8(mapInd),
+	newVar := &Y2KVar{
+		ID:     id,
 		Size:   uint8(len(input)),
 		strVal: input,
-		numVal: utils.StrToFloat(input),
+		numVal: numVal,
 		Type:   argType,
 	}
+
+	if argType == Y2KInt {
+		if intVal, ok := new(big.Int).SetString(input, 10); ok {
+			newVar.setInt(intVal)
+		} else {
+			newVar.Type = Y2KFloat
+		}
+	}
+
+	return newVar
+}
+
+// variableChunks returns the number of chunks in the value of a variable.
+// Strings use one chunk per character, lists use one chunk per item, and all
+// other types use one digit per character.
+func variableChunks(fields []int, digits int) int {
+	switch Y2KVarType(fields[varType]) {
+	case Y2KString, Y2KList:
+		return fields[varSize]
+	}
+
+	return chunksFor(fields[varSize], digits)
 }
 
-// ParseVariable recursively builds a new Y2KVar to insert into the global
+// parseVariable builds a new Y2KVar to insert into the interpreter's
 // variable map.
 // The variable creation process follows a specific order:
 //
@@ -105,53 +349,82 @@ func (y2k Y2K) FromCLIArg(input string) {
 // chain of values would need to be:
 //
 // 3 1 2 3 1 0 0
-func (y2k Y2K) ParseVariable(timestamp string, val reflect.Value) string {
-	newVar := val.Interface().(Y2KVar)
-	input := timestamp[:y2k.Digits]
-
-	y2k.DebugMsg("ParseVariable: [%s]%s",
-		input,
-		timestamp[y2k.Digits:],
-	)
-
-	// Regardless of data type, var values are created as a string first, in
-	// order to sequentially create the variable value across multiple passes
-	// of the parser (i.e. 100 has to be split between multiple passes, so "1"
-	// is added first, then "0", then the last "0", then converted to an
-	// integer).
+func (m *machine) parseVariable(ins *Instruction) error {
+	newVar := Y2KVar{
+		ID:   uint8(ins.Fields[varID]),
+		Type: Y2KVarType(ins.Fields[varType]),
+		Size: uint8(ins.Fields[varSize]),
+	}
+
+	switch newVar.Type {
+	case Y2KList:
+		return m.createList(ins)
+	case Y2KArgs:
+		m.vars[newVar.ID] = m.argsList(newVar.ID)
+		return nil
+	}
+
+	// Strings are decoded from one character code per chunk. Regardless of
+	// data type, all other values are created as a string first, in order
+	// to sequentially create the variable value across multiple chunks
+	// (i.e. 100 has to be split between multiple chunks, so "1" is added
+	// first, then "0", then the last "0", then converted to an integer).
+	chunks := utils.SplitStrByN(ins.Value, ins.Digits)
 	if newVar.Type == Y2KString {
-		input = string(utils.Printable[utils.StrToInt(input)])
+		text, err := m.decodeText(chunks[:newVar.Size], ins.valueOffset, ins)
+		if err != nil {
+			return err
+		}
+
+		newVar.strVal = text
+	} else {
+		newVar.strVal = strings.Join(chunks, "")
 	}
-	newVar.strVal += input
 
-	if len(newVar.strVal) >= int(newVar.Size) {
+	// Strings have one chunk per character, but numbers can have padding
+	// in their last chunk
+	if newVar.Type != Y2KString {
 		newVar.strVal = newVar.strVal[:newVar.Size]
+	}
 
-		if newVar.Type == Y2KVarCopy {
-			copyVar := GetVar(uint8(utils.StrToInt(newVar.strVal)))
-			newVar.Type = copyVar.Type
-			newVar.Size = copyVar.Size
-			newVar.numVal = copyVar.numVal
-			newVar.strVal = copyVar.strVal
-		} else {
-			// Init numeric value of variable
-			if newVar.Type == Y2KFloat {
-				// First digit of a float is where the decimal should be placed
-				decimalIndex := utils.StrToInt(newVar.strVal[0:1])
-				newVar.strVal = newVar.strVal[1:decimalIndex+1] +
-					"." +
-					newVar.strVal[decimalIndex+1:]
+	if newVar.Type == Y2KVarCopy {
+		copyVar, err := m.varByID(newVar.strVal, ins.valueOffset)
+		if err != nil {
+			return err
+		}
+
+		newVar = *copyVar.clone(newVar.ID)
+	} else {
+		// Init numeric value of variable
+		if newVar.Type == Y2KFloat {
+			// First digit of a float is where the decimal should be placed
+			if len(newVar.strVal) == 0 ||
+				utils.StrToInt(newVar.strVal[0:1])+1 > len(newVar.strVal) {
+				return m.errorAt(ins.valueOffset, "value", fmt.Errorf(
+					"%w: decimal position is out of range for %q",
+					ErrInvalidValue,
+					newVar.strVal))
 			}
 
-			newVar.numVal = utils.StrToFloat(newVar.strVal)
+			decimalIndex := utils.StrToInt(newVar.strVal[0:1])
+			newVar.strVal = newVar.strVal[1:decimalIndex+1] +
+				"." +
+				newVar.strVal[decimalIndex+1:]
 		}
 
-		// Insert finished variable into variable map
-		VarMap[newVar.ID] = &newVar
+		newVar.numVal = utils.StrToFloat(newVar.strVal)
+		if newVar.Type == Y2KInt {
+			intVal, ok := new(big.Int).SetString(newVar.strVal, 10)
+			if !ok {
+				intVal = new(big.Int)
+			}
 
-		// Return handling of the parser back to Parse
-		return timestamp
+			newVar.setInt(intVal)
+		}
 	}
 
-	return y2k.ParseVariable(timestamp[y2k.Digits:], reflect.ValueOf(newVar))
+	// Insert finished variable into variable map
+	m.vars[newVar.ID] = &newVar
+
+	return nil
 }
@@ -7,6 +7,7 @@ import (
 	"os"
 	"strings"
 	"time"
+	"unicode"
 )
 
 var commentChar = "#"
@@ -20,20 +21,23 @@ var commentChar = "#"
 //
 // The file is read line by line, and whitespace and comments are ignored, so
 // Y2K programs can take up as much space as needed to make sense without
-// impacting the interpreter.
-func ReadY2KRawFile(file string) string {
-	timestamp := ""
+// impacting the interpreter. The returned source map records which line and
+// column each digit of the timestamp came from.
+func ReadY2KRawFile(file string) (string, *SourceMap, error) {
+	var timestamp strings.Builder
+	source := &SourceMap{}
 	raw, err := os.Open(file)
-	Check(err)
+	if err != nil {
+		return "", nil, err
+	}
 
 	defer func(raw *os.File) {
-		err := raw.Close()
-		Check(err)
+		_ = raw.Close()
 	}(raw)
 
 	// Strip all whitespace and comments from file
 	scanner := bufio.NewScanner(raw)
-	for scanner.Scan() {
+	for lineNum := 1; scanner.Scan(); lineNum++ {
 		line := scanner.Text()
 
 		// Remove any comments from line
@@ -42,25 +46,41 @@ func ReadY2KRawFile(file string) string {
 			line = line[:commentIndex]
 		}
 
-		// Remove extra whitespace
-		line = strings.ReplaceAll(line, " ", "")
+		// Remove extra whitespace, appending each remaining run of
+		// characters to the timestamp and recording the column that it
+		// started at
+		column := 1
+		start := -1
+		for i, c := range line {
+			if unicode.IsSpace(c) {
+				if start >= 0 {
+					timestamp.WriteString(line[start:i])
+					start = -1
+				}
+			} else if start < 0 {
+				source.add(timestamp.Len(), Position{
+					File:   file,
+					Line:   lineNum,
+					Column: column,
+				})
+				start = i
+			}
+
+			column += 1
+		}
 
-		// Append to timestamp
-		timestamp += line
+		if start >= 0 {
+			timestamp.WriteString(line[start:])
+		}
 	}
 
-	return timestamp
+	return timestamp.String(), source, scanner.Err()
 }
 
 // WriteFileTimestamp creates an empty file at <path>/<fileNum>.y2k and modifies
 // the file's timestamp with the value provided.
-func WriteFileTimestamp(timestamp string, path string, fileNum int) {
+func WriteFileTimestamp(timestamp string, path string, fileNum int) error {
 	filename := fmt.Sprintf("%s/%d.y2k", path, fileNum)
-	file, err := os.Create(filename)
-	Check(err)
-
-	err = file.Close()
-	Check(err)
 
 	// Prepend a digit for all file timestamps after the first file. The reason
 	// for this is explained in the README.
@@ -69,27 +89,40 @@ func WriteFileTimestamp(timestamp string, path string, fileNum int) {
 	}
 
 	if len(timestamp) != 18 {
-		panic("Error: Invalid timestamp length -- must be 18 chars long")
+		return fmt.Errorf(
+			"invalid timestamp length for %s -- must be 18 chars long",
+			filename)
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return err
 	}
 
 	fileTime := time.Unix(int64(StrToInt(timestamp[:9])), int64(StrToInt(timestamp[9:])))
 
 	fmt.Println(fmt.Sprintf("Writing %s -- %s (%s)", filename, timestamp, fileTime))
 
-	err = os.Chtimes(filename, fileTime, fileTime)
-	Check(err)
+	return os.Chtimes(filename, fileTime, fileTime)
 }
 
 // ExportRawToTimestampFiles takes the timestamp created from a raw Y2K file
 // and outputs a set of empty files that have their timestamps modified to
 // perform the same operations as the raw file.
-func ExportRawToTimestampFiles(timestamp string, path string) {
+func ExportRawToTimestampFiles(timestamp string, path string) error {
 	files := 0
 
 	// Ensure path exists, and create it if not
 	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
 		err := os.Mkdir(path, os.ModePerm)
-		Check(err)
+		if err != nil {
+			return err
+		}
 	}
 
 	for len(timestamp) > 0 {
@@ -106,8 +139,14 @@ func ExportRawToTimestampFiles(timestamp string, path string) {
 			timestamp += "0"
 		}
 
-		WriteFileTimestamp(timestamp[:maxLen], path, files)
+		err := WriteFileTimestamp(timestamp[:maxLen], path, files)
+		if err != nil {
+			return err
+		}
+
 		timestamp = timestamp[maxLen:]
 		files += 1
 	}
+
+	return nil
 }
@@ -0,0 +1,59 @@
+package utils_test
+
+import (
+	"github.com/benbusby/y2k/src/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadY2KRawFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prog.y2k")
+	raw := "# Print \"a\"\n9 1\t1 1 # comment\n\n  81 2 2  42\n"
+	if err := os.WriteFile(path, []byte(raw), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp, source, err := utils.ReadY2KRawFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if timestamp != "9111812242" {
+		t.Fatalf("timestamp = %q, want %q", timestamp, "9111812242")
+	}
+
+	// Each digit should map back to its line and column
+	want := []utils.Position{
+		{File: path, Line: 2, Column: 1},
+		{File: path, Line: 2, Column: 3},
+		{File: path, Line: 2, Column: 5},
+		{File: path, Line: 2, Column: 7},
+		{File: path, Line: 4, Column: 3},
+		{File: path, Line: 4, Column: 4},
+		{File: path, Line: 4, Column: 6},
+		{File: path, Line: 4, Column: 8},
+		{File: path, Line: 4, Column: 11},
+		{File: path, Line: 4, Column: 12},
+	}
+	for offset, pos := range want {
+		if got, ok := source.Lookup(offset); !ok || got != pos {
+			t.Errorf("Lookup(%d) = %+v, %v, want %+v", offset, got, ok, pos)
+		}
+	}
+}
+
+func BenchmarkReadY2KRawFile(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "big.y2k")
+	raw := strings.Repeat("  7 1 1 0 1 1   # add 1 to v1\n", 20000)
+	if err := os.WriteFile(path, []byte(raw), 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err := utils.ReadY2KRawFile(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Position describes where a digit of a Y2K program was read from. Digits
+// read from raw files have a line and column, while digits read from file
+// timestamps have a digit index within that file's timestamp.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+	Digit  int
+}
+
+func (pos Position) String() string {
+	if pos.Line > 0 {
+		return fmt.Sprintf("%s line %d, column %d", pos.File, pos.Line, pos.Column)
+	}
+
+	return fmt.Sprintf("%s digit %d", pos.File, pos.Digit)
+}
+
+// SourceMap maps digit offsets in a concatenated timestamp back to the
+// files (and raw file lines) that the digits were read from.
+type SourceMap struct {
+	spans []sourceSpan
+}
+
+// sourceSpan is a run of consecutive digits that were read from consecutive
+// positions of a single file, starting at pos.
+type sourceSpan struct {
+	offset int
+	pos    Position
+}
+
+// add records that the digits starting at offset were read from pos. Spans
+// must be added in increasing offset order.
+func (m *SourceMap) add(offset int, pos Position) {
+	m.spans = append(m.spans, sourceSpan{offset: offset, pos: pos})
+}
+
+// Lookup returns the position that the digit at the given offset was read
+// from. The second return value is false if the offset isn't covered by the
+// source map (or if the source map is nil).
+func (m *SourceMap) Lookup(offset int) (Position, bool) {
+	if m == nil || len(m.spans) == 0 || offset < m.spans[0].offset {
+		return Position{}, false
+	}
+
+	// Find the last span that starts at or before the offset
+	i := sort.Search(len(m.spans), func(i int) bool {
+		return m.spans[i].offset > offset
+	})
+
+	span := m.spans[i-1]
+	pos := span.pos
+	if pos.Line > 0 {
+		pos.Column += offset - span.offset
+	} else {
+		pos.Digit += offset - span.offset
+	}
+
+	return pos, true
+}
+
+// Describe returns a string representation of the position of the digit at
+// the given offset, or an empty string if the position isn't known.
+func (m *SourceMap) Describe(offset int) string {
+	if pos, ok := m.Lookup(offset); ok {
+		return pos.String()
+	}
+
+	return ""
+}
@@ -0,0 +1,171 @@
+package utils_test
+
+import (
+	"github.com/benbusby/y2k/src/utils"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTimestampFile creates an empty file with the timestamp (in
+// nanoseconds) as its modification time.
+func writeTimestampFile(t *testing.T, path string, timestamp int64) {
+	t.Helper()
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fileTime := time.Unix(0, timestamp)
+	if err := os.Chtimes(path, fileTime, fileTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetTimestamps(t *testing.T) {
+	dir := t.TempDir()
+
+	raw := filepath.Join(dir, "raw.y2k")
+	if err := os.WriteFile(raw, []byte("# Comment\n9 2\n  1 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	single := filepath.Join(dir, "single.y2k")
+	writeTimestampFile(t, single, 912345678123456789)
+
+	multi := filepath.Join(dir, "multi")
+	if err := os.Mkdir(multi, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTimestampFile(t, filepath.Join(multi, "00.y2k"), 912345678123456789)
+	writeTimestampFile(t, filepath.Join(multi, "01.y2k"), 812345678987654321)
+	if err := os.WriteFile(filepath.Join(multi, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	first := filepath.Join(multi, "00.y2k")
+	second := filepath.Join(multi, "01.y2k")
+
+	tests := []struct {
+		name      string
+		path      string
+		digits    int
+		timestamp string
+		positions map[int]utils.Position
+	}{
+		{
+			name:      "raw file",
+			path:      raw,
+			digits:    1,
+			timestamp: "9211",
+			positions: map[int]utils.Position{
+				0: {File: raw, Line: 2, Column: 1},
+				1: {File: raw, Line: 2, Column: 3},
+				2: {File: raw, Line: 3, Column: 3},
+				3: {File: raw, Line: 3, Column: 5},
+			},
+		},
+		{
+			name:      "raw file with 2 digits",
+			path:      raw,
+			digits:    2,
+			timestamp: "9211",
+			positions: map[int]utils.Position{
+				0: {File: raw, Line: 2, Column: 1},
+				3: {File: raw, Line: 3, Column: 5},
+			},
+		},
+		{
+			name:      "timestamp file",
+			path:      single,
+			digits:    1,
+			timestamp: "912345678123456789",
+			positions: map[int]utils.Position{
+				0:  {File: single, Digit: 1},
+				17: {File: single, Digit: 18},
+			},
+		},
+		{
+			name:      "timestamp file with 2 digits",
+			path:      single,
+			digits:    2,
+			timestamp: "0912345678123456789",
+			positions: map[int]utils.Position{
+				0:  {File: single, Digit: 0},
+				1:  {File: single, Digit: 1},
+				18: {File: single, Digit: 18},
+			},
+		},
+		{
+			name:      "directory",
+			path:      multi,
+			digits:    1,
+			timestamp: "912345678123456789" + "12345678987654321",
+			positions: map[int]utils.Position{
+				0:  {File: first, Digit: 1},
+				17: {File: first, Digit: 18},
+				18: {File: second, Digit: 2},
+				34: {File: second, Digit: 18},
+			},
+		},
+		{
+			name:      "directory with 2 digits",
+			path:      multi,
+			digits:    2,
+			timestamp: "0912345678123456789" + "12345678987654321",
+			positions: map[int]utils.Position{
+				0:  {File: first, Digit: 0},
+				18: {File: first, Digit: 18},
+				19: {File: second, Digit: 2},
+				35: {File: second, Digit: 18},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			timestamp, source, err := utils.GetTimestamps(tc.path, tc.digits)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if timestamp != tc.timestamp {
+				t.Errorf("timestamp = %q, want %q", timestamp, tc.timestamp)
+			}
+
+			for offset, want := range tc.positions {
+				if pos, ok := source.Lookup(offset); !ok || pos != want {
+					t.Errorf("Lookup(%d) = %+v, %v, want %+v", offset, pos, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSourceMapLookup(t *testing.T) {
+	var source *utils.SourceMap
+	if _, ok := source.Lookup(0); ok {
+		t.Error("Lookup on a nil source map should return false")
+	}
+	if desc := source.Describe(0); desc != "" {
+		t.Errorf("Describe on a nil source map = %q, want \"\"", desc)
+	}
+
+	path := filepath.Join(t.TempDir(), "prog.y2k")
+	if err := os.WriteFile(path, []byte("9 1 1 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, source, err := utils.GetTimestamps(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := source.Lookup(-1); ok {
+		t.Error("Lookup(-1) should return false")
+	}
+	if desc, want := source.Describe(2), path+" line 1, column 5"; desc != want {
+		t.Errorf("Describe(2) = %q, want %q", desc, want)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 var Y2KExt = ".y2k"
@@ -18,7 +19,7 @@ var MaxTimestamp = int64(999999999999999999)
 var StrTerm = "  "
 var LoopTerm = "1999"
 var CondTerm = "2000"
-var ContinueCmd = "continue"
+var ElseMarker = "2001"
 var DebugDivider = "=============================="
 
 func GetFileModTime(path string, zeroPad bool) string {
@@ -90,18 +91,47 @@ func StrArrToFloat(input []string) float64 {
 	return numVal
 }
 
-func StrArrToPrintable(input []string) string {
+// StrArrToText converts character codes to a string, skipping codes that
+// don't have a character (and empty codes, which are left over from
+// splitting an empty string). Codes are read as Unicode code points if
+// unicode is true, or as indexes of Printable otherwise.
+func StrArrToText(input []string, unicode bool) string {
 	output := ""
 	for _, val := range input {
-		index := StrToInt(val)
-		if index < len(Printable) {
-			output += string(Printable[index])
+		if len(val) == 0 {
+			continue
+		}
+
+		if char, ok := CodeToChar(StrToInt(val), unicode); ok {
+			output += string(char)
 		}
 	}
 
 	return output
 }
 
+// CodeToChar returns the character for a character code, or false if the
+// code doesn't have a character (see StrArrToText).
+func CodeToChar(code int, unicode bool) (rune, bool) {
+	if unicode {
+		return rune(code), code <= utf8.MaxRune && utf8.ValidRune(rune(code))
+	} else if code < 0 || code >= len(Printable) {
+		return 0, false
+	}
+
+	return rune(Printable[code]), true
+}
+
+// CharToCode returns the character code for a character, or -1 if the
+// character doesn't have a code (see StrArrToText).
+func CharToCode(char rune, unicode bool) int {
+	if unicode {
+		return int(char)
+	}
+
+	return strings.IndexRune(Printable, char)
+}
+
 func SplitStrByN(input string, n int) []string {
 	var output []string
 
@@ -114,21 +144,38 @@ func SplitStrByN(input string, n int) []string {
 	return output
 }
 
-func GetFileTimestamp(file string, digits int) string {
+// firstDigit returns the index of the first digit of a file timestamp, as used
+// in source positions. Timestamps read in multi-digit mode are zero padded
+// (see GetFileModTime), and the padding is counted as digit 0.
+func firstDigit(digits int) int {
+	if digits > 1 {
+		return 0
+	}
+
+	return 1
+}
+
+func GetFileTimestamp(file string, digits int) (string, *SourceMap, error) {
 	// Check to see if this file is a timestamp-only file (which is the case
 	// if GetFileModTime finds a timestamp pre-2000) or if it's a "raw" file
 	fileModTime := GetFileModTime(file, digits > 1)
 
 	if len(fileModTime) > 0 {
-		return fileModTime
+		source := &SourceMap{}
+		source.add(0, Position{File: file, Digit: firstDigit(digits)})
+		return fileModTime, source, nil
 	}
 
 	// File was made after 2000, so we can assume it's likely a raw file
 	return ReadY2KRawFile(file)
 }
 
-func GetTimestamps(dir string, digits int) string {
+// GetTimestamps reads the timestamps of all Y2K files in a directory (or a
+// single file) and concatenates them, returning the full timestamp and a
+// source map of which file each digit was read from.
+func GetTimestamps(dir string, digits int) (string, *SourceMap, error) {
 	var fullTimestamp = ""
+	var source = &SourceMap{}
 	files, err := os.ReadDir(dir)
 
 	// If the input is not a directory, try reading it as a file
@@ -154,21 +201,24 @@ func GetTimestamps(dir string, digits int) string {
 		// Append timestamp to slice
 		fullPath := filepath.Join(directoryPath, file.Name())
 		timestamp := GetFileModTime(fullPath, digits > 1)
+		fileDigit := firstDigit(digits)
 		if len(fullTimestamp) != 0 && len(timestamp) > 0 {
 			// Snip off the leading digit for all timestamps except
 			// the first one. We do this to avoid issues with commands
 			// spanning across multiple files, where the next desired
 			// digit might be a "0" (which would be ignored in a timestamp)
 			timestamp = timestamp[digits:]
+			fileDigit += digits
+		}
+
+		if len(timestamp) > 0 {
+			source.add(len(fullTimestamp), Position{
+				File:  filepath.Join(dir, file.Name()),
+				Digit: fileDigit,
+			})
 		}
 		fullTimestamp += timestamp
 	}
 
-	return fullTimestamp
-}
-
-func Check(err error) {
-	if err != nil {
-		panic(err)
-	}
+	return fullTimestamp, source, nil
 }
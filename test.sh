@@ -6,9 +6,15 @@ SKIP_TEST="SKIP_TEST"
 SCRIPT_DIR="$(CDPATH= command cd -- "$(dirname -- "$0")" && pwd -P)"
 TEST_DIR="$SCRIPT_DIR/test-output"
 
+# Input for examples that read from stdin
+TEST_INPUT="21"
+
 echo "- Building executable"
 go build
 
+echo "- Running unit tests"
+go test ./...
+
 echo "- Running tests"
 for example in examples/*; do
     # Set up test directory for raw Y2K file exports
@@ -22,11 +28,11 @@ for example in examples/*; do
     fi
 
     # Evaluate the expected output of a Y2K example file
-    expected="$(./y2k $example 15)"
+    expected="$(echo "$TEST_INPUT" | ./y2k $example 15)"
 
     # Export the raw file to a set of empty timestamp files
     ./y2k -outdir $TEST_DIR -export $example >/dev/null
-    output="$(./y2k $TEST_DIR 15)"
+    output="$(echo "$TEST_INPUT" | ./y2k $TEST_DIR 15)"
 
     # Check if both outputs are equal
     if [ "$output" != "$expected" ]; then